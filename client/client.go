@@ -0,0 +1,234 @@
+// Package client is a typed Go client for the World One web API, for bots and
+// tooling written against it.
+//
+// Every request carries an X-TRACE-UUID, taken from the context if one was
+// added with ContextWithTraceUUID, else generated per call, so that the
+// server's logs for a call can be found. Failed requests that are safe to
+// retry are retried with a jittered backoff, and mutations are sent with an
+// Idempotency-Key so that retrying them can't apply them twice.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	traceUUIDHeader          = "X-TRACE-UUID"
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+type Options struct {
+	// HTTPClient is optional, defaulting to http.DefaultClient. Its timeout,
+	// if any, applies to each attempt.
+	HTTPClient *http.Client
+	// MaxAttempts is optional, defaulting to 4. 1 disables retries.
+	MaxAttempts int
+	// BaseBackoff is optional, defaulting to 100ms. Each retry waits a random
+	// duration up to BaseBackoff doubled per previous attempt, capped at
+	// MaxBackoff, unless the server said how long to wait with Retry-After.
+	BaseBackoff time.Duration
+	// MaxBackoff is optional, defaulting to 5s.
+	MaxBackoff time.Duration
+}
+
+// Client calls the World One web API. It is safe for concurrent use.
+type Client struct {
+	baseURL *url.URL
+	opts    Options
+}
+
+// New creates a Client for the API hosted at baseURL, like
+// http://localhost:8080.
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("expected the base URL to be http or https, got %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 4
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	return &Client{baseURL: u, opts: opts}, nil
+}
+
+type contextKey int
+
+const (
+	traceUUIDContextKey contextKey = iota
+	idempotencyKeyContextKey
+)
+
+// ContextWithTraceUUID makes the calls made with the returned context send
+// traceUUID as their X-TRACE-UUID, so that several calls can be correlated.
+func ContextWithTraceUUID(ctx context.Context, traceUUID uuid.UUID) context.Context {
+	return context.WithValue(ctx, traceUUIDContextKey, traceUUID)
+}
+
+// ContextWithIdempotencyKey makes the mutations made with the returned
+// context send key as their Idempotency-Key instead of a generated one. This
+// is for callers that retry on their own, such as after restarting, and need
+// the server to recognize the retry.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey, key)
+}
+
+// response is a successful response, whose body has already been read.
+type response struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+// do sends a request to path, retrying it while that's safe. Responses with
+// a status outside of okStatuses are returned as an *Error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, okStatuses ...int) (response, error) {
+	target := *c.baseURL
+	target.Path += path
+	target.RawQuery = query.Encode()
+
+	// The trace UUID and idempotency key are the same for every attempt, so
+	// the server sees one call retried rather than several calls.
+	traceUUID, ok := ctx.Value(traceUUIDContextKey).(uuid.UUID)
+	if !ok {
+		var err error
+		traceUUID, err = uuid.NewV7()
+		if err != nil {
+			return response{}, fmt.Errorf("failed to create a trace UUID: %w", err)
+		}
+	}
+	var idempotencyKey string
+	mutation := method != http.MethodGet && method != http.MethodHead
+	if mutation {
+		idempotencyKey, _ = ctx.Value(idempotencyKeyContextKey).(string)
+		if idempotencyKey == "" {
+			idempotencyKey = uuid.NewString()
+		}
+	}
+
+	backoffCap := c.opts.BaseBackoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+		if err != nil {
+			return response{}, fmt.Errorf("failed to create the request: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set(traceUUIDHeader, traceUUID.String())
+		if mutation {
+			req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+		}
+
+		resp, err := c.attempt(req, okStatuses)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= c.opts.MaxAttempts || !retryable(err) {
+			return response{}, err
+		}
+
+		backoff := time.Duration(rand.Int64N(int64(backoffCap) + 1))
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			backoff = apiErr.RetryAfter
+		}
+		backoffCap = min(backoffCap*2, c.opts.MaxBackoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return response{}, fmt.Errorf("gave up retrying: %w", errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(req *http.Request, okStatuses []int) (response, error) {
+	httpResp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return response{}, &transportError{err: err}
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return response{}, &transportError{err: fmt.Errorf("failed to read the response: %w", err)}
+	}
+
+	for _, status := range okStatuses {
+		if httpResp.StatusCode == status {
+			return response{header: httpResp.Header, status: httpResp.StatusCode, body: body}, nil
+		}
+	}
+	return response{}, newError(httpResp, body)
+}
+
+// transportError is a request that failed without a response, which is
+// retried since the server may not have received it.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+func retryable(err error) bool {
+	var transportErr *transportError
+	if errors.As(err, &transportErr) {
+		// The caller's context ending isn't worth retrying.
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusConflict, http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+func decode[T any](resp response) (T, error) {
+	var v T
+	if err := json.Unmarshal(resp.body, &v); err != nil {
+		return v, fmt.Errorf("failed to decode the %d response: %w", resp.status, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Era is a period of the game, ended by a rollover. The current era's
+// EndTime is 2200-01-01T00:00:00Z.
+type Era struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+// ErasPage is a page of eras. Next is the query of the next page to pass to
+// ListErasPage, or nil if this is the last page.
+type ErasPage struct {
+	Eras []Era
+	Next url.Values
+}
+
+// ListErasPage gets a page of eras in the order they were created. If query
+// is nil, the first page is returned; pageSize, if above 0, is how many eras
+// to return at most.
+func (c *Client) ListErasPage(ctx context.Context, pageSize int, query url.Values) (ErasPage, error) {
+	query = maps.Clone(query)
+	if query == nil {
+		query = url.Values{}
+	}
+	if pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/v1/eras", query, http.StatusOK)
+	if err != nil {
+		return ErasPage{}, err
+	}
+	eras, err := decode[[]Era](resp)
+	if err != nil {
+		return ErasPage{}, err
+	}
+	next, err := nextPageQuery(resp.header)
+	if err != nil {
+		return ErasPage{}, err
+	}
+	return ErasPage{Eras: eras, Next: next}, nil
+}
+
+// Eras iterates over every era, getting them pageSize at a time (or the
+// server's default if 0). Iteration stops after the first error.
+func (c *Client) Eras(ctx context.Context, pageSize int) iter.Seq2[Era, error] {
+	return func(yield func(Era, error) bool) {
+		var query url.Values
+		for {
+			page, err := c.ListErasPage(ctx, pageSize, query)
+			if err != nil {
+				yield(Era{}, err)
+				return
+			}
+			for _, era := range page.Eras {
+				if !yield(era, nil) {
+					return
+				}
+			}
+			if page.Next == nil {
+				return
+			}
+			query = page.Next
+		}
+	}
+}
+
+// CurrentEra gets the era that has not ended yet.
+func (c *Client) CurrentEra(ctx context.Context) (Era, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/eras/current", nil, http.StatusOK)
+	if err != nil {
+		return Era{}, err
+	}
+	return decode[Era](resp)
+}
+
+// RolloverResult is the outcome of a rollover. Prev is nil if there was no
+// current era to end. Replayed is true if the server had already handled the
+// rollover, such as when an earlier attempt's response was lost.
+type RolloverResult struct {
+	New      Era  `json:"newEraDTO"`
+	Prev     *Era `json:"prevEraDTO"`
+	Replayed bool `json:"-"`
+}
+
+// Rollover ends the current era, soft resets the game, and creates a new era
+// named newEraName. If there is no current era, the first one is created.
+func (c *Client) Rollover(ctx context.Context, newEraName string) (RolloverResult, error) {
+	query := url.Values{"newEraName": {newEraName}}
+	resp, err := c.do(ctx, http.MethodPost, "/v1/eras/rollover", query, http.StatusCreated)
+	if err != nil {
+		return RolloverResult{}, err
+	}
+	result, err := decode[RolloverResult](resp)
+	if err != nil {
+		return RolloverResult{}, err
+	}
+	result.Replayed = resp.header.Get(idempotentReplayedHeader) == "true"
+	return result, nil
+}
+
+var nextLink = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// nextPageQuery returns the query of the Link header's next page, or nil if
+// there isn't one.
+func nextPageQuery(header http.Header) (url.Values, error) {
+	for _, link := range header.Values("Link") {
+		match := nextLink.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+		next, err := url.Parse(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the next page's link: %w", err)
+		}
+		return next.Query(), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// ProblemTypeTimeout is the Error.Type of a request that ran longer than the
+// server permits.
+const ProblemTypeTimeout = "urn:world-one:problem:timeout"
+
+// Error is an unsuccessful response from the API, decoded from its problem
+// details (https://datatracker.ietf.org/doc/html/rfc7807) if it had them.
+type Error struct {
+	Status int
+	// Type identifies the kind of problem, such as ProblemTypeTimeout. It's
+	// empty if the response wasn't a problem, and about:blank if the status
+	// says it all.
+	Type   string
+	Title  string
+	Detail string
+	// TraceUUID is the X-TRACE-UUID of the request, useful when reporting
+	// issues.
+	TraceUUID string
+	// Violations lists every way the request didn't match the API's spec,
+	// when that is why it was a 400.
+	Violations []Violation
+	// RetryAfter is how long the server asked to wait before retrying, or 0.
+	RetryAfter time.Duration
+}
+
+// Violation is one way a request didn't match the API's spec. In is where
+// the offending value was (path, query, header, or body), and Name is the
+// parameter's name or, for bodies, the JSON pointer to the value.
+type Violation struct {
+	In     string `json:"in"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("world one responded %d %s", e.Status, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, v := range e.Violations {
+		msg += fmt.Sprintf("; %s %s: %s", v.In, v.Name, v.Reason)
+	}
+	if e.TraceUUID != "" {
+		msg += " (trace UUID " + e.TraceUUID + ")"
+	}
+	return msg
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		Status:     resp.StatusCode,
+		Title:      http.StatusText(resp.StatusCode),
+		TraceUUID:  resp.Header.Get(traceUUIDHeader),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" {
+		return e
+	}
+
+	var details struct {
+		Type       string      `json:"type"`
+		Title      string      `json:"title"`
+		Detail     string      `json:"detail"`
+		TraceUUID  string      `json:"traceUUID"`
+		Violations []Violation `json:"violations"`
+	}
+	if err := json.Unmarshal(body, &details); err != nil {
+		e.Detail = "the problem details could not be decoded: " + err.Error()
+		return e
+	}
+	e.Type = details.Type
+	if details.Title != "" {
+		e.Title = details.Title
+	}
+	e.Detail = details.Detail
+	if details.TraceUUID != "" {
+		e.TraceUUID = details.TraceUUID
+	}
+	e.Violations = details.Violations
+	return e
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusHealthy   HealthStatus = "Healthy"
+	HealthStatusDegraded  HealthStatus = "Degraded"
+	HealthStatusUnhealthy HealthStatus = "Unhealthy"
+)
+
+// Probe selects the subset of health checks an orchestrator acts on.
+type Probe string
+
+const (
+	ProbeLiveness  Probe = "live"
+	ProbeReadiness Probe = "ready"
+	ProbeStartup   Probe = "startup"
+)
+
+// HealthOverview is the latest results of the health checks, which the
+// server evaluates in the background.
+type HealthOverview struct {
+	Status      HealthStatus `json:"status"`
+	Duration    string       `json:"duration"`
+	EvaluatedAt time.Time    `json:"evaluatedAt"`
+	Age         string       `json:"age"`
+	// ShuttingDown is true when readiness fails due to graceful shutdown.
+	ShuttingDown bool          `json:"shuttingDown"`
+	Checks       []HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Name     string       `json:"name"`
+	Status   HealthStatus `json:"status"`
+	Duration string       `json:"duration"`
+	// Critical checks make the overview unhealthy when they're unhealthy.
+	Critical    bool           `json:"critical"`
+	TimedOut    bool           `json:"timedOut"`
+	EvaluatedAt time.Time      `json:"evaluatedAt"`
+	Age         string         `json:"age"`
+	Payload     map[string]any `json:"payloadDict"`
+	// History and Transitions are only present if requested.
+	History     []HealthCheckSample   `json:"history"`
+	Transitions []HealthStatusChanged `json:"transitions"`
+}
+
+type HealthCheckSample struct {
+	Status      HealthStatus `json:"status"`
+	Duration    string       `json:"duration"`
+	TimedOut    bool         `json:"timedOut"`
+	EvaluatedAt time.Time    `json:"evaluatedAt"`
+}
+
+type HealthStatusChanged struct {
+	From HealthStatus `json:"from"`
+	To   HealthStatus `json:"to"`
+	At   time.Time    `json:"at"`
+}
+
+// HealthChecks gets the latest results of every health check. An unhealthy
+// overview is returned rather than an error, so check its Status. If history
+// is true, each check's recent results and status transitions are included.
+func (c *Client) HealthChecks(ctx context.Context, history bool) (HealthOverview, error) {
+	return c.getHealth(ctx, "/healthChecks", history)
+}
+
+// Probe gets the latest results of the health checks participating in probe.
+// Like HealthChecks, an unhealthy overview is not an error.
+func (c *Client) Probe(ctx context.Context, probe Probe, history bool) (HealthOverview, error) {
+	return c.getHealth(ctx, "/health/"+string(probe), history)
+}
+
+func (c *Client) getHealth(ctx context.Context, path string, history bool) (HealthOverview, error) {
+	var query url.Values
+	if history {
+		query = url.Values{"history": {"true"}}
+	}
+	// Unhealthy is a 503, which isn't retried since it's the answer.
+	resp, err := c.do(ctx, http.MethodGet, path, query, http.StatusOK, http.StatusServiceUnavailable)
+	if err != nil {
+		return HealthOverview{}, err
+	}
+	return decode[HealthOverview](resp)
+}
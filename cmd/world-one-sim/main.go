@@ -0,0 +1,101 @@
+// Command world-one-sim load tests a running World One server by simulating
+// players using the web API while eras are rolled over, and reports each
+// endpoint's latency percentiles, error rate, and timeouts.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sawyerwatts/world-one/client"
+)
+
+const usage = `Usage: world-one-sim [flags]
+
+Simulate players against a running server for -duration, rolling over the eras
+every -rollover-every, and then report each endpoint's latency percentiles,
+error rate, and requests cut off by the server's request timeout. Requests
+that overlapped a rollover are reported separately.
+
+The server must already have a current era, see world-one seed. Rollovers
+create real eras, so don't point this at a server whose data matters.
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("world-one-sim", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	serverURL := fs.String("server", "http://localhost:8080", "the URL of the server to load")
+	var config simConfig
+	fs.IntVar(&config.players, "players", 100, "how many players to simulate")
+	fs.DurationVar(&config.duration, "duration", time.Minute, "how long to run for")
+	fs.DurationVar(&config.ramp, "ramp", 10*time.Second, "how long it takes for every player to join")
+	fs.DurationVar(&config.think, "think", time.Second, "the mean time players wait between actions")
+	fs.DurationVar(&config.rolloverEvery, "rollover-every", 20*time.Second, "how often to roll over the eras, 0 disables rollovers")
+	requestTimeout := fs.Duration("request-timeout", 30*time.Second, "how long the client waits for each response")
+	maxAttempts := fs.Int("max-attempts", 1, "how many times the client tries each request; above 1, retries hide failures from the report")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return 2
+	}
+	if config.players < 1 || config.duration <= 0 || config.ramp < 0 || config.think <= 0 || config.rolloverEvery < 0 {
+		fmt.Fprintln(stderr, "-players, -duration, and -think must be positive, and -ramp and -rollover-every must not be negative")
+		return 2
+	}
+
+	// Every player shares the client, so it needs a connection per player
+	// rather than the default's two idle connections per host.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.players
+	transport.MaxIdleConnsPerHost = config.players
+	c, err := client.New(*serverURL, client.Options{
+		HTTPClient:  &http.Client{Transport: transport, Timeout: *requestTimeout},
+		MaxAttempts: *maxAttempts,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid -server:", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if _, err := c.CurrentEra(ctx); err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusInternalServerError {
+			fmt.Fprintln(stderr, "The server has no current era, seed one with: world-one seed -scenario fresh-game")
+		} else {
+			fmt.Fprintln(stderr, "Failed to reach the server:", err)
+		}
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "Simulating %d players against %s for %s\n", config.players, *serverURL, config.duration)
+	s := &sim{client: c, config: config, recorder: newRecorder()}
+	start := time.Now()
+	rollovers := s.run(ctx, stderr)
+	elapsed := time.Since(start)
+
+	fmt.Fprintf(stdout, "Ran %d players for %s with %d successful rollovers\n\n", config.players, elapsed.Round(time.Millisecond), rollovers)
+	if err := s.recorder.report(stdout, elapsed); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
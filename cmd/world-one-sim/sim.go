@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sawyerwatts/world-one/client"
+)
+
+const (
+	endpointCurrentEra = "GET /v1/eras/current"
+	endpointListEras   = "GET /v1/eras"
+	endpointRollover   = "POST /v1/eras/rollover"
+
+	// erasPageSize is what a player's era history screen would show.
+	erasPageSize = 20
+)
+
+type simConfig struct {
+	players int
+	// duration is how long the players play for, after which the run ends.
+	duration time.Duration
+	// ramp is how long it takes for every player to join, at an even rate.
+	ramp time.Duration
+	// think is the mean time a player waits between actions.
+	think time.Duration
+	// rolloverEvery is how often the eras are rolled over, 0 disables them.
+	rolloverEvery time.Duration
+}
+
+// sim runs simulated players against a server.
+type sim struct {
+	client   *client.Client
+	config   simConfig
+	recorder *recorder
+
+	// rolloverSeq is incremented when a rollover starts and again when it
+	// ends, so it's odd while one is running, and a request overlapped one if
+	// it changed while the request ran.
+	rolloverSeq atomic.Uint64
+	active      atomic.Int64
+	requests    atomic.Int64
+}
+
+// timed calls fn, recording its latency and outcome under endpoint. Requests
+// cut off because the run ended aren't recorded.
+func (s *sim) timed(ctx context.Context, endpoint string, fn func() error) error {
+	seqBefore := s.rolloverSeq.Load()
+	start := time.Now()
+	err := fn()
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		return err
+	}
+	p := phaseSteady
+	if seqBefore%2 == 1 || s.rolloverSeq.Load() != seqBefore {
+		p = phaseRollover
+	}
+	s.recorder.record(endpoint, p, latency, err)
+	s.requests.Add(1)
+	return err
+}
+
+// run plays until the configured duration elapses or ctx is done, returning
+// how many rollovers were made.
+func (s *sim) run(ctx context.Context, progress io.Writer) int {
+	ctx, cancel := context.WithTimeout(ctx, s.config.duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	rollovers := 0
+	if s.config.rolloverEvery > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rollovers = s.operate(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.reportProgress(ctx, progress)
+	}()
+
+	joinEvery := s.config.ramp / time.Duration(s.config.players)
+	for i := range s.config.players {
+		if i > 0 && joinEvery > 0 && !sleep(ctx, joinEvery) {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.play(ctx, rand.New(rand.NewPCG(rand.Uint64(), uint64(i))))
+		}()
+	}
+
+	wg.Wait()
+	return rollovers
+}
+
+// play is a player's session: they load the game, and then check on the
+// current era and browse the era history between thinking.
+func (s *sim) play(ctx context.Context, rng *rand.Rand) {
+	s.active.Add(1)
+	defer s.active.Add(-1)
+
+	_ = s.timed(ctx, endpointCurrentEra, func() error {
+		_, err := s.client.CurrentEra(ctx)
+		return err
+	})
+	for {
+		// Think times vary from half to one and a half times the mean.
+		think := s.config.think/2 + time.Duration(rng.Int64N(int64(s.config.think)+1))
+		if !sleep(ctx, think) {
+			return
+		}
+
+		switch roll := rng.IntN(100); {
+		case roll < 70:
+			_ = s.timed(ctx, endpointCurrentEra, func() error {
+				_, err := s.client.CurrentEra(ctx)
+				return err
+			})
+		case roll < 95:
+			_ = s.timed(ctx, endpointListEras, func() error {
+				_, err := s.client.ListErasPage(ctx, erasPageSize, nil)
+				return err
+			})
+		default:
+			// Occasionally a player reads the whole history.
+			var page client.ErasPage
+			for {
+				err := s.timed(ctx, endpointListEras, func() error {
+					var err error
+					page, err = s.client.ListErasPage(ctx, erasPageSize, page.Next)
+					return err
+				})
+				if err != nil || page.Next == nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// operate rolls over the eras every rolloverEvery, like an operator would
+// mid-game, returning how many rollovers succeeded.
+func (s *sim) operate(ctx context.Context) int {
+	ticker := time.NewTicker(s.config.rolloverEvery)
+	defer ticker.Stop()
+	succeeded := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return succeeded
+		case <-ticker.C:
+		}
+
+		name := "Simulated era " + time.Now().UTC().Format(time.RFC3339Nano)
+		s.rolloverSeq.Add(1)
+		err := s.timed(ctx, endpointRollover, func() error {
+			_, err := s.client.Rollover(ctx, name)
+			return err
+		})
+		s.rolloverSeq.Add(1)
+		if err == nil {
+			succeeded++
+		}
+	}
+}
+
+func (s *sim) reportProgress(ctx context.Context, w io.Writer) {
+	start := time.Now()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Fprintf(w, "%s: %d players active, %d requests\n",
+				time.Since(start).Round(time.Second), s.active.Load(), s.requests.Load())
+		}
+	}
+}
+
+// sleep waits for d, returning false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
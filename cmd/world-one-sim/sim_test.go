@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/client"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"github.com/sawyerwatts/world-one/internal/db"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	for _, tc := range []struct {
+		p        int
+		expected time.Duration
+	}{
+		{p: 0, expected: time.Millisecond},
+		{p: 50, expected: 50 * time.Millisecond},
+		{p: 99, expected: 99 * time.Millisecond},
+		{p: 100, expected: 100 * time.Millisecond},
+	} {
+		if got := percentile(sorted, tc.p); got != tc.expected {
+			t.Errorf("Expected p%d to be %s, got %s", tc.p, tc.expected, got)
+		}
+	}
+	if got := percentile([]time.Duration{time.Second}, 50); got != time.Second {
+		t.Errorf("Expected the only latency, got %s", got)
+	}
+}
+
+func TestRecorderCountsTimeouts(t *testing.T) {
+	r := newRecorder()
+	r.record(endpointCurrentEra, phaseSteady, time.Millisecond, nil)
+	r.record(endpointCurrentEra, phaseSteady, time.Second, &client.Error{
+		Status: http.StatusServiceUnavailable,
+		Type:   client.ProblemTypeTimeout,
+	})
+	r.record(endpointCurrentEra, phaseSteady, time.Millisecond, &client.Error{Status: http.StatusServiceUnavailable})
+	r.record(endpointCurrentEra, phaseSteady, time.Millisecond, errors.New("connection refused"))
+
+	s := r.stats[statsKey{endpoint: endpointCurrentEra, phase: phaseSteady}]
+	if len(s.latencies) != 4 || s.errors != 3 || s.timeouts != 1 {
+		t.Fatalf("Expected 4 requests, 3 errors, and 1 timeout, got %+v", s)
+	}
+	if got := formatStatuses(s.statuses); got != "transport:1 503:2" {
+		t.Fatalf("Unexpected statuses %q", got)
+	}
+}
+
+// newSimTestServer serves the eras endpoints over store, which must have a
+// current era, cutting requests off after requestTimeout.
+func newSimTestServer(t *testing.T, store eras.Store, requestTimeout time.Duration) *client.Client {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(
+		middleware.UseTraceUUIDAndSlogger(context.Background(), slogger),
+		middleware.UseIdempotency(middleware.NewIdempotencyCache(time.Minute, 100, clock.System{})))
+	api := openapi.NewGroup(openapi.NewSpec(openapi.Info{}), &router.RouterGroup)
+	eras.Route(api.Group("/v1"), store, clock.System{})
+	server := httptest.NewServer(middleware.TimeoutHandler(router, func() time.Duration { return requestTimeout }))
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL, client.Options{HTTPClient: server.Client(), MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newSeededStore(t *testing.T) *eras.MemStore {
+	t.Helper()
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := eras.NewMemStore(clock.System{})
+	_, _, err := eras.Rollover(context.Background(), eras.MakeQueries(store, slogger), store, slogger, time.Now().UTC(), "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// slowStore takes delay to get the current era.
+type slowStore struct {
+	eras.Store
+	delay time.Duration
+}
+
+func (s slowStore) GetCurrEra(ctx context.Context) (db.Era, error) {
+	select {
+	case <-time.After(s.delay):
+		return s.Store.GetCurrEra(ctx)
+	case <-ctx.Done():
+		return db.Era{}, ctx.Err()
+	}
+}
+
+func TestSimAgainstServer(t *testing.T) {
+	s := &sim{
+		client: newSimTestServer(t, newSeededStore(t), 5*time.Second),
+		config: simConfig{
+			players:       5,
+			duration:      300 * time.Millisecond,
+			ramp:          50 * time.Millisecond,
+			think:         10 * time.Millisecond,
+			rolloverEvery: 50 * time.Millisecond,
+		},
+		recorder: newRecorder(),
+	}
+	rollovers := s.run(context.Background(), io.Discard)
+	if rollovers == 0 {
+		t.Fatal("Expected at least one rollover")
+	}
+
+	for key, stats := range s.recorder.stats {
+		if stats.errors != 0 {
+			t.Errorf("Expected no errors for %s during %s, got %v", key.endpoint, key.phase, stats.statuses)
+		}
+	}
+	var report bytes.Buffer
+	if err := s.recorder.report(&report, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range []string{endpointCurrentEra, endpointRollover} {
+		if !strings.Contains(report.String(), endpoint) {
+			t.Errorf("Expected the report to include %s:\n%s", endpoint, report.String())
+		}
+	}
+}
+
+func TestSimCountsServerTimeouts(t *testing.T) {
+	s := &sim{
+		client: newSimTestServer(t, slowStore{Store: newSeededStore(t), delay: time.Second}, 10*time.Millisecond),
+		config: simConfig{
+			players:  2,
+			duration: 200 * time.Millisecond,
+			think:    10 * time.Millisecond,
+		},
+		recorder: newRecorder(),
+	}
+	s.run(context.Background(), io.Discard)
+
+	stats := s.recorder.stats[statsKey{endpoint: endpointCurrentEra, phase: phaseSteady}]
+	if stats == nil || stats.timeouts == 0 || stats.timeouts != stats.errors {
+		t.Fatalf("Expected every failed request for the current era to be a timeout, got %+v", stats)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sawyerwatts/world-one/client"
+)
+
+// phase is whether a request overlapped a rollover, since that's when the
+// eras table is contended.
+type phase string
+
+const (
+	phaseSteady   phase = "steady"
+	phaseRollover phase = "rollover"
+)
+
+type statsKey struct {
+	endpoint string
+	phase    phase
+}
+
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+	timeouts  int
+	// statuses counts the unsuccessful responses by status, with 0 for
+	// requests that failed without a response.
+	statuses map[int]int
+}
+
+// recorder collects the outcome of every request. It is safe for concurrent
+// use.
+type recorder struct {
+	mu    sync.Mutex
+	stats map[statsKey]*endpointStats
+}
+
+func newRecorder() *recorder {
+	return &recorder{stats: make(map[statsKey]*endpointStats)}
+}
+
+// record adds a request to endpoint that took latency and failed with err, if
+// not nil.
+func (r *recorder) record(endpoint string, p phase, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := statsKey{endpoint: endpoint, phase: p}
+	s, ok := r.stats[key]
+	if !ok {
+		s = &endpointStats{statuses: make(map[int]int)}
+		r.stats[key] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	if err == nil {
+		return
+	}
+	s.errors++
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		s.statuses[0]++
+		return
+	}
+	s.statuses[apiErr.Status]++
+	if isTimeout(apiErr) {
+		s.timeouts++
+	}
+}
+
+// isTimeout reports whether the server's TimeoutHandler cut the request off,
+// as opposed to the handler itself responding 503.
+func isTimeout(apiErr *client.Error) bool {
+	return apiErr.Type == client.ProblemTypeTimeout
+}
+
+// report writes a row per endpoint and phase, followed by the error
+// statuses seen.
+func (r *recorder) report(w io.Writer, elapsed time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]statsKey, 0, len(r.stats))
+	for key := range r.stats {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b statsKey) int {
+		return cmp.Or(cmp.Compare(a.endpoint, b.endpoint), cmp.Compare(a.phase, b.phase))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENDPOINT\tPHASE\tREQUESTS\tRPS\tERRORS\tERROR %\tTIMEOUTS\tP50\tP90\tP99\tMAX\tERROR STATUSES\t")
+	for _, key := range keys {
+		s := r.stats[key]
+		slices.Sort(s.latencies)
+		n := len(s.latencies)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%d\t%.2f\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+			key.endpoint,
+			key.phase,
+			n,
+			float64(n)/elapsed.Seconds(),
+			s.errors,
+			100*float64(s.errors)/float64(n),
+			s.timeouts,
+			formatLatency(percentile(s.latencies, 50)),
+			formatLatency(percentile(s.latencies, 90)),
+			formatLatency(percentile(s.latencies, 99)),
+			formatLatency(s.latencies[n-1]),
+			formatStatuses(s.statuses))
+	}
+	return tw.Flush()
+}
+
+// percentile returns the nearest-rank percentile p of sorted, which must not
+// be empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func formatLatency(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
+
+func formatStatuses(statuses map[int]int) string {
+	if len(statuses) == 0 {
+		return "-"
+	}
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name := fmt.Sprint(code)
+		if code == 0 {
+			name = "transport"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d", name, statuses[code]))
+	}
+	return strings.Join(parts, " ")
+}
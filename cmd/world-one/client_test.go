@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawyerwatts/world-one/client"
+)
+
+// newClientTestServer serves the real router, optionally wrapped, and returns
+// a client for it.
+func newClientTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*specTestEnv, *client.Client) {
+	t.Helper()
+	env, router := newSpecTestRouter(t, specTestCase{})
+	var handler http.Handler = router
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(server.URL, client.Options{
+		HTTPClient:  server.Client(),
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, c
+}
+
+func TestClientPagesThroughEras(t *testing.T) {
+	env, c := newClientTestServer(t, nil)
+	names := []string{"First", "Second", "Third", "Fourth", "Fifth"}
+	for _, name := range names {
+		rollover(t, env, name)
+	}
+
+	page, err := c.ListErasPage(context.Background(), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Eras) != 2 || page.Next == nil {
+		t.Fatalf("Expected a full first page with a next page, got %+v", page)
+	}
+
+	var got []string
+	for era, err := range c.Eras(context.Background(), 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, era.Name)
+	}
+	if len(got) != len(names) {
+		t.Fatalf("Expected %v, got %v", names, got)
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Fatalf("Expected %v, got %v", names, got)
+		}
+	}
+}
+
+func TestClientRollsOver(t *testing.T) {
+	_, c := newClientTestServer(t, nil)
+	ctx := context.Background()
+
+	first, err := c.Rollover(ctx, "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.New.Name != "First" || first.Prev != nil || first.Replayed {
+		t.Fatalf("Unexpected first rollover: %+v", first)
+	}
+
+	second, err := c.Rollover(ctx, "Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Prev == nil || second.Prev.ID != first.New.ID {
+		t.Fatalf("Expected the first era to have been ended, got %+v", second)
+	}
+
+	curr, err := c.CurrentEra(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if curr.ID != second.New.ID {
+		t.Fatalf("Expected the current era to be %s, got %s", second.New.ID, curr.ID)
+	}
+}
+
+func TestClientDecodesProblems(t *testing.T) {
+	env, c := newClientTestServer(t, nil)
+	rollover(t, env, "First")
+	traceUUID := uuid.Must(uuid.NewV7())
+	ctx := client.ContextWithTraceUUID(context.Background(), traceUUID)
+
+	_, err := c.Rollover(ctx, "First")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected a *client.Error, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || apiErr.Detail == "" {
+		t.Fatalf("Expected a 400 with detail, got %+v", apiErr)
+	}
+	if apiErr.TraceUUID != traceUUID.String() {
+		t.Fatalf("Expected trace UUID %s, got %s", traceUUID, apiErr.TraceUUID)
+	}
+
+	_, err = c.ListErasPage(ctx, 1000, nil)
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected a *client.Error, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || len(apiErr.Violations) != 1 || apiErr.Violations[0].Name != "pageSize" {
+		t.Fatalf("Expected a pageSize violation, got %+v", apiErr)
+	}
+}
+
+// TestClientRetriesMutationsIdempotently loses the response to the first
+// rollover, so the client's retry must be replayed rather than rolling over
+// again.
+func TestClientRetriesMutationsIdempotently(t *testing.T) {
+	var lost atomic.Bool
+	env, c := newClientTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && lost.CompareAndSwap(false, true) {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	result, err := c.Rollover(context.Background(), "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Replayed {
+		t.Fatalf("Expected the retry to be replayed, got %+v", result)
+	}
+	all, err := env.store.GetEras(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("Expected one era to have been created, got %d", len(all))
+	}
+}
+
+func TestClientGivesUpRetrying(t *testing.T) {
+	var attempts atomic.Int32
+	_, c := newClientTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+	})
+
+	_, err := c.CurrentEra(context.Background())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a 503, got %v", err)
+	}
+	if attempts.Load() != 4 {
+		t.Fatalf("Expected 4 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestClientGetsHealthChecks(t *testing.T) {
+	env, c := newClientTestServer(t, nil)
+	ctx := context.Background()
+
+	overview, err := c.Probe(ctx, client.ProbeReadiness, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overview.Status != client.HealthStatusUnhealthy {
+		t.Fatalf("Expected unevaluated readiness to be unhealthy, got %s", overview.Status)
+	}
+
+	rollover(t, env, "First")
+	env.runner.Evaluate(ctx)
+	overview, err = c.HealthChecks(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overview.Status != client.HealthStatusHealthy || len(overview.Checks) == 0 {
+		t.Fatalf("Expected healthy checks, got %+v", overview)
+	}
+}
@@ -1,93 +1,291 @@
 package main
 
 import (
-	"embed"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
 	"os"
-	"strings"
-)
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
 
-//go:embed config.json
-var embeddedConfig embed.FS
+	"github.com/jackc/pgx/v5"
+	"github.com/sawyerwatts/world-one/internal/common/tracing"
+)
 
+// mainConfig is resolved from layers, see loadConfig. Each field can be set
+// by a W1_* environment variable and a command-line flag whose names are
+// derived from the field's name (DBAcquireWaitDegradedThresholdMS is
+// W1_DB_ACQUIRE_WAIT_DEGRADED_THRESHOLD_MS and
+// -db-acquire-wait-degraded-threshold-ms) unless overridden by an env or flag
+// struct tag. Fields tagged secret are redacted when shown, and fields tagged
+// live can be changed by reloading the config while the app is running (see
+// configReloader); every other field requires a restart.
+//
+// Field notes:
+//
+// - AdminAddr is optional; when set, operational endpoints such as /metrics
+// are served on it instead of Addr.
+//
+// - ShutdownDrainMS is how long readiness fails before the server begins
+// shutting down, giving orchestrators time to stop routing traffic here.
+//
+// - IdempotencyKeyTTLSec is how long the response to a mutation sent with an
+// Idempotency-Key is replayed to retries, and IdempotencyMaxKeys is how many
+// are kept at most.
+//
+// - HealthCheckIntervalMS is how often health checks are evaluated in the
+// background; health endpoints serve the latest results.
+//
+// - TracingExporter is one of none, stdout, or otlp.
+//
+// - AutoMigrate applies pending migrations at startup. Concurrent instances
+// are serialized by an advisory lock, waiting up to MigrationLockTimeoutSec.
+//
+// - DBAcquireWaitDegradedThresholdMS is the average wait to acquire a pooled
+// connection above which the pool is reported as saturated (degraded).
+//
+// - DBMaxConns, DBMinConns, DBMaxConnLifetimeSec, DBMaxConnIdleTimeSec, and
+// DBHealthCheckPeriodSec configure the connection pool, taking precedence
+// over any pool_* parameters in DBConnectionString.
+//
+// - DBStatementTimeoutMS is each connection's statement_timeout, 0 disables
+// it. DBApplicationName is each connection's application_name.
+//
+// - DBConnectWaitSec is how long startup retries connecting to the database
+// before giving up; 0 skips waiting, leaving readiness to report it.
+//
+// - SlogLevel is one of DEBUG, INFO, WARN, or ERROR, optionally with an
+// offset such as INFO+2.
+//
+// - FeatureFlags is a comma-separated list of the feature flags to enable,
+// see the features package.
 type mainConfig struct {
-	TimeZone               string
-	Addr                   string
-	ReadHeaderTimeoutMS    int
-	ReadTimeoutMS          int
-	IdleTimeoutMS          int
-	RequestTimeoutMS       int
-	MaxGracefulShutdownSec int
-	SlogIncludeSource      bool
-	DBConnectionString     string `mapstructure:"PGURL"`
-	WebsiteDir             string
+	TimeZone                         string
+	Addr                             string
+	AdminAddr                        string
+	ReadHeaderTimeoutMS              int
+	ReadTimeoutMS                    int
+	IdleTimeoutMS                    int
+	RequestTimeoutMS                 int `reload:"live"`
+	MaxGracefulShutdownSec           int `reload:"live"`
+	ShutdownDrainMS                  int `reload:"live"`
+	IdempotencyKeyTTLSec             int
+	IdempotencyMaxKeys               int
+	SlogLevel                        string `reload:"live"`
+	SlogIncludeSource                bool
+	HealthCheckIntervalMS            int
+	HealthCheckHistorySize           int
+	DBConnectionString               string `env:"W1_PGURL" secret:"true"`
+	DBAcquireWaitDegradedThresholdMS int
+	DBMaxConns                       int
+	DBMinConns                       int
+	DBMaxConnLifetimeSec             int
+	DBMaxConnIdleTimeSec             int
+	DBHealthCheckPeriodSec           int
+	DBStatementTimeoutMS             int
+	DBApplicationName                string
+	DBConnectWaitSec                 int
+	AutoMigrate                      bool
+	MigrationLockTimeoutSec          int
+	WebsiteDir                       string
+	TracingExporter                  string
+	TracingOTLPEndpointURL           string
+	FeatureFlags                     string `reload:"live"`
 }
 
-func mustGetConfig() *mainConfig {
-	mainConfig := newMainConfig()
-
-	configBytes, err := embeddedConfig.ReadFile("config.json")
-	if err != nil {
-		panic("embedded config filesystem failed to retrieve file: " + err.Error())
+func newMainConfig() *mainConfig {
+	return &mainConfig{
+		TimeZone:                         "GMT",
+		Addr:                             "",
+		AdminAddr:                        "",
+		ReadHeaderTimeoutMS:              500,
+		ReadTimeoutMS:                    500,
+		IdleTimeoutMS:                    30_000,
+		RequestTimeoutMS:                 10_000,
+		MaxGracefulShutdownSec:           5,
+		ShutdownDrainMS:                  1_000,
+		IdempotencyKeyTTLSec:             86_400,
+		IdempotencyMaxKeys:               10_000,
+		SlogLevel:                        slog.LevelInfo.String(),
+		SlogIncludeSource:                false,
+		HealthCheckIntervalMS:            10_000,
+		HealthCheckHistorySize:           20,
+		DBConnectionString:               "",
+		DBAcquireWaitDegradedThresholdMS: 100,
+		DBMaxConns:                       10,
+		DBMinConns:                       0,
+		DBMaxConnLifetimeSec:             3_600,
+		DBMaxConnIdleTimeSec:             1_800,
+		DBHealthCheckPeriodSec:           60,
+		DBStatementTimeoutMS:             5_000,
+		DBApplicationName:                "world-one",
+		DBConnectWaitSec:                 30,
+		AutoMigrate:                      false,
+		MigrationLockTimeoutSec:          15,
+		TracingExporter:                  tracing.ExporterNone,
+		TracingOTLPEndpointURL:           "http://localhost:4318",
+		FeatureFlags:                     "",
 	}
-	json.Unmarshal(configBytes, mainConfig)
+}
 
-	const pgurlEnv = "W1_PGURL"
-	if pgurl, ok := os.LookupEnv(pgurlEnv); !ok {
-		panic("there is not an environment variable " + pgurlEnv)
-	} else {
-		pgurl = strings.TrimSpace(pgurl)
-		if pgurl == "" {
-			panic("environment variable " + pgurlEnv + " is whitespace or empty")
-		}
-		mainConfig.DBConnectionString = pgurl
-	}
+// exitCodeInvalidConfig is EX_CONFIG from sysexits.h.
+const exitCodeInvalidConfig = 78
 
-	return mainConfig
+type configValidation struct {
+	field string
+	check func(c *mainConfig) error
 }
 
-func newMainConfig() *mainConfig {
-	return &mainConfig{
-		TimeZone:               "GMT",
-		Addr:                   "",
-		ReadHeaderTimeoutMS:    500,
-		ReadTimeoutMS:          500,
-		IdleTimeoutMS:          30_000,
-		RequestTimeoutMS:       10_000,
-		MaxGracefulShutdownSec: 5,
-		SlogIncludeSource:      false,
-		DBConnectionString:     "",
-	}
+var configValidations = []configValidation{
+	{"TimeZone", func(c *mainConfig) error {
+		if c.TimeZone == "" {
+			return errors.New("is not initialized")
+		}
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("could not be loaded: %w", err)
+		}
+		return nil
+	}},
+	{"Addr", func(c *mainConfig) error {
+		if c.Addr == "" {
+			return errors.New("is not initialized")
+		}
+		return validateAddr(c.Addr)
+	}},
+	{"AdminAddr", func(c *mainConfig) error {
+		if c.AdminAddr == "" {
+			return nil
+		}
+		if c.AdminAddr == c.Addr {
+			return errors.New("is the same as Addr")
+		}
+		return validateAddr(c.AdminAddr)
+	}},
+	{"ReadHeaderTimeoutMS", func(c *mainConfig) error { return validatePositive(c.ReadHeaderTimeoutMS) }},
+	{"ReadTimeoutMS", func(c *mainConfig) error { return validatePositive(c.ReadTimeoutMS) }},
+	{"IdleTimeoutMS", func(c *mainConfig) error { return validatePositive(c.IdleTimeoutMS) }},
+	{"RequestTimeoutMS", func(c *mainConfig) error { return validatePositive(c.RequestTimeoutMS) }},
+	{"MaxGracefulShutdownSec", func(c *mainConfig) error { return validatePositive(c.MaxGracefulShutdownSec) }},
+	{"ShutdownDrainMS", func(c *mainConfig) error { return validateNonNegative(c.ShutdownDrainMS) }},
+	{"IdempotencyKeyTTLSec", func(c *mainConfig) error { return validatePositive(c.IdempotencyKeyTTLSec) }},
+	{"IdempotencyMaxKeys", func(c *mainConfig) error { return validatePositive(c.IdempotencyMaxKeys) }},
+	{"SlogLevel", func(c *mainConfig) error {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.SlogLevel)); err != nil {
+			return errors.New("is not one of DEBUG, INFO, WARN, or ERROR")
+		}
+		return nil
+	}},
+	{"HealthCheckIntervalMS", func(c *mainConfig) error { return validatePositive(c.HealthCheckIntervalMS) }},
+	{"HealthCheckHistorySize", func(c *mainConfig) error { return validateNonNegative(c.HealthCheckHistorySize) }},
+	{"DBConnectionString", func(c *mainConfig) error {
+		if c.DBConnectionString == "" {
+			return errors.New("is not initialized, set environment variable W1_PGURL")
+		}
+		// The error is not wrapped since it may contain the connection
+		// string's password.
+		if _, err := pgx.ParseConfig(c.DBConnectionString); err != nil {
+			return errors.New("could not be parsed as a Postgres connection string")
+		}
+		return nil
+	}},
+	{"DBAcquireWaitDegradedThresholdMS", func(c *mainConfig) error {
+		return validateNonNegative(c.DBAcquireWaitDegradedThresholdMS)
+	}},
+	{"DBMaxConns", func(c *mainConfig) error {
+		if c.DBMaxConns > math.MaxInt32 {
+			return errors.New("is too large")
+		}
+		return validatePositive(c.DBMaxConns)
+	}},
+	{"DBMinConns", func(c *mainConfig) error {
+		if c.DBMinConns > c.DBMaxConns {
+			return errors.New("is greater than DBMaxConns")
+		}
+		return validateNonNegative(c.DBMinConns)
+	}},
+	{"DBMaxConnLifetimeSec", func(c *mainConfig) error { return validatePositive(c.DBMaxConnLifetimeSec) }},
+	{"DBMaxConnIdleTimeSec", func(c *mainConfig) error { return validatePositive(c.DBMaxConnIdleTimeSec) }},
+	{"DBHealthCheckPeriodSec", func(c *mainConfig) error { return validatePositive(c.DBHealthCheckPeriodSec) }},
+	{"DBStatementTimeoutMS", func(c *mainConfig) error { return validateNonNegative(c.DBStatementTimeoutMS) }},
+	{"DBConnectWaitSec", func(c *mainConfig) error { return validateNonNegative(c.DBConnectWaitSec) }},
+	{"MigrationLockTimeoutSec", func(c *mainConfig) error { return validatePositive(c.MigrationLockTimeoutSec) }},
+	{"WebsiteDir", func(c *mainConfig) error {
+		if c.WebsiteDir == "" {
+			return errors.New("is not initialized")
+		}
+		info, err := os.Stat(c.WebsiteDir)
+		if err != nil {
+			return fmt.Errorf("could not be read: %w", err)
+		}
+		if !info.IsDir() {
+			return errors.New("is not a directory")
+		}
+		if _, err := os.Stat(filepath.Join(c.WebsiteDir, "scalar-v1.html")); err != nil {
+			return fmt.Errorf("does not contain scalar-v1.html: %w", err)
+		}
+		return nil
+	}},
+	{"TracingExporter", func(c *mainConfig) error {
+		switch c.TracingExporter {
+		case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+			return nil
+		}
+		return errors.New("is not one of none, stdout, or otlp")
+	}},
+	{"TracingOTLPEndpointURL", func(c *mainConfig) error {
+		if c.TracingExporter != tracing.ExporterOTLP {
+			return nil
+		}
+		if c.TracingOTLPEndpointURL == "" {
+			return errors.New("is not initialized")
+		}
+		if _, err := url.ParseRequestURI(c.TracingOTLPEndpointURL); err != nil {
+			return fmt.Errorf("is not a URL: %w", err)
+		}
+		return nil
+	}},
 }
 
-func (c *mainConfig) Validate() error {
-	if c.TimeZone == "" {
-		return errors.New("config TimeZone is not initialized")
-	}
-	if c.Addr == "" {
-		return errors.New("config Addr is not initialized")
-	}
-	if c.ReadHeaderTimeoutMS < 1 {
-		return errors.New("config ReadHeaderTimeoutMS is not positive")
-	}
-	if c.ReadTimeoutMS < 1 {
-		return errors.New("config ReadTimeoutMS is not positive")
-	}
-	if c.IdleTimeoutMS < 1 {
-		return errors.New("config IdleTimeoutMS is not positive")
-	}
-	if c.RequestTimeoutMS < 1 {
-		return errors.New("config RequestTimeoutMS is not positive")
+// Validate checks every field (or only the given fields, if any) and returns
+// all of the problems found, each naming the field and the layer that set it.
+func (c *mainConfig) Validate(sources configSources, fields ...string) error {
+	var errs []error
+	for _, validation := range configValidations {
+		if len(fields) > 0 && !slices.Contains(fields, validation.field) {
+			continue
+		}
+		if err := validation.check(c); err != nil {
+			errs = append(errs, fmt.Errorf("config %s (from %s) %w", validation.field, sources[validation.field], err))
+		}
 	}
-	if c.MaxGracefulShutdownSec < 1 {
-		return errors.New("config MaxGracefulShutdownSec is not positive")
+	return errors.Join(errs...)
+}
+
+func validateAddr(addr string) error {
+	if _, port, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("is not a host:port address: %w", err)
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("does not have a valid port: %w", err)
 	}
-	if c.DBConnectionString == "" {
-		return errors.New("config DBConnectionString is not initialized")
+	return nil
+}
+
+func validatePositive(i int) error {
+	if i < 1 {
+		return errors.New("is not positive")
 	}
-	if c.WebsiteDir == "" {
-		return errors.New("config WebsiteDir is not initialized")
+	return nil
+}
+
+func validateNonNegative(i int) error {
+	if i < 0 {
+		return errors.New("is negative")
 	}
 	return nil
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+//go:embed config.json
+var embeddedConfig embed.FS
+
+const configFileEnv = "W1_CONFIG_FILE"
+
+// configSources maps each mainConfig field's name to the layer that set its
+// final value.
+type configSources map[string]string
+
+// configField describes one mainConfig field and how it can be set.
+type configField struct {
+	name   string
+	env    string
+	flag   string
+	secret bool
+	live   bool
+}
+
+// registerConfigFlags adds a flag per mainConfig field (plus -config for an
+// external config file) to fs. Once fs has been parsed, call the returned
+// func to load the config.
+//
+// The layers are applied in this order, so later layers take precedence:
+//
+// 1. Defaults from newMainConfig.
+//
+// 2. The embedded config.json.
+//
+// 3. The external config file given by -config or W1_CONFIG_FILE, if any.
+//
+// 4. W1_* environment variables.
+//
+// 5. Command-line flags.
+func registerConfigFlags(fs *flag.FlagSet) func() (*mainConfig, configSources, error) {
+	fields := configFields()
+
+	configFile := fs.String("config", "", "path to an external JSON config file (env "+configFileEnv+")")
+	flagValues := make(map[string]string, len(fields))
+	for _, field := range fields {
+		usage := fmt.Sprintf("overrides config %s (env %s)", field.name, field.env)
+		if isBoolField(field.name) {
+			fs.BoolFunc(field.flag, usage, func(value string) error {
+				flagValues[field.name] = value
+				return nil
+			})
+		} else {
+			fs.Func(field.flag, usage, func(value string) error {
+				flagValues[field.name] = value
+				return nil
+			})
+		}
+	}
+
+	return func() (*mainConfig, configSources, error) {
+		return loadConfig(fields, *configFile, flagValues, os.LookupEnv)
+	}
+}
+
+func loadConfig(
+	fields []configField,
+	configFile string,
+	flagValues map[string]string,
+	lookupEnv func(string) (string, bool),
+) (*mainConfig, configSources, error) {
+	mainConfig := newMainConfig()
+	sources := make(configSources, len(fields))
+	for _, field := range fields {
+		sources[field.name] = "default"
+	}
+
+	configBytes, err := embeddedConfig.ReadFile("config.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("embedded config filesystem failed to retrieve file: %w", err)
+	}
+	if err := applyConfigJSON(mainConfig, sources, configBytes, "embedded config.json"); err != nil {
+		return nil, nil, err
+	}
+
+	if configFile == "" {
+		if fromEnv, ok := lookupEnv(configFileEnv); ok {
+			configFile = strings.TrimSpace(fromEnv)
+		}
+	}
+	if configFile != "" {
+		configBytes, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := applyConfigJSON(mainConfig, sources, configBytes, "file "+configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, field := range fields {
+		value, ok := lookupEnv(field.env)
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil, fmt.Errorf("environment variable %s is whitespace or empty", field.env)
+		}
+		if err := setConfigField(mainConfig, field.name, value); err != nil {
+			return nil, nil, fmt.Errorf("environment variable %s: %w", field.env, err)
+		}
+		sources[field.name] = "env " + field.env
+	}
+
+	for _, field := range fields {
+		value, ok := flagValues[field.name]
+		if !ok {
+			continue
+		}
+		if err := setConfigField(mainConfig, field.name, value); err != nil {
+			return nil, nil, fmt.Errorf("flag -%s: %w", field.flag, err)
+		}
+		sources[field.name] = "flag -" + field.flag
+	}
+
+	return mainConfig, sources, nil
+}
+
+// applyConfigJSON sets the fields present in configBytes, erroring on any
+// unknown fields rather than silently ignoring typos.
+func applyConfigJSON(mainConfig *mainConfig, sources configSources, configBytes []byte, source string) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(configBytes, &raw); err != nil {
+		return fmt.Errorf("%s is not a JSON object: %w", source, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(configBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(mainConfig); err != nil {
+		return fmt.Errorf("%s could not be parsed: %w", source, err)
+	}
+
+	for key := range raw {
+		for name := range sources {
+			// This matches encoding/json's case-insensitive field matching.
+			if strings.EqualFold(key, name) {
+				sources[name] = source
+			}
+		}
+	}
+	return nil
+}
+
+func setConfigField(mainConfig *mainConfig, name string, value string) error {
+	field := reflect.ValueOf(mainConfig).Elem().FieldByName(name)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("config %s has unsupported kind %s", name, field.Kind())
+	}
+	return nil
+}
+
+func isBoolField(name string) bool {
+	field, _ := reflect.TypeOf(mainConfig{}).FieldByName(name)
+	return field.Type.Kind() == reflect.Bool
+}
+
+func configFields() []configField {
+	t := reflect.TypeOf(mainConfig{})
+	fields := make([]configField, 0, t.NumField())
+	for i := range t.NumField() {
+		structField := t.Field(i)
+		words := splitFieldName(structField.Name)
+		field := configField{
+			name:   structField.Name,
+			env:    "W1_" + strings.ToUpper(strings.Join(words, "_")),
+			flag:   strings.ToLower(strings.Join(words, "-")),
+			secret: structField.Tag.Get("secret") == "true",
+			live:   structField.Tag.Get("reload") == "live",
+		}
+		if env := structField.Tag.Get("env"); env != "" {
+			field.env = env
+		}
+		if flagName := structField.Tag.Get("flag"); flagName != "" {
+			field.flag = flagName
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// splitFieldName splits a Go identifier into words, keeping initialisms
+// together: TracingOTLPEndpointURL is Tracing, OTLP, Endpoint, URL.
+func splitFieldName(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prevLower := unicode.IsLower(runes[i-1])
+		currUpper := unicode.IsUpper(runes[i])
+		nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if currUpper && (prevLower || (nextLower && unicode.IsUpper(runes[i-1]))) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// printConfig writes each field's resolved value and source, redacting
+// secrets.
+func printConfig(w io.Writer, mainConfig *mainConfig, sources configSources) error {
+	fields := configFields()
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tVALUE\tSOURCE")
+	v := reflect.ValueOf(mainConfig).Elem()
+	for _, field := range fields {
+		value := fmt.Sprint(v.FieldByName(field.name).Interface())
+		if field.secret {
+			value = redact(value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", field.name, value, sources[field.name])
+	}
+	return tw.Flush()
+}
+
+// redact hides a URL's password, or the whole value if it isn't a URL with
+// user info.
+func redact(value string) string {
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil || u.Host == "" {
+		return "[REDACTED]"
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	redacted := u.String()
+	if u.RawQuery != "" {
+		// Query parameters such as password= can hold secrets too.
+		redacted = strings.TrimSuffix(redacted, "?"+u.RawQuery) + "?[REDACTED]"
+	}
+	return redacted
+}
+
+const configUsage = `Usage: world-one config show [flags]
+
+Print each resolved config value and the layer that set it. Secrets are
+redacted.
+`
+
+// runConfig runs the config subcommand and returns the process's exit code.
+func runConfig(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprint(stderr, configUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	load := registerConfigFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 {
+		return 2
+	}
+	mainConfig, sources, err := load()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := printConfig(stdout, mainConfig, sources); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := mainConfig.Validate(sources); err != nil {
+		fmt.Fprintln(stderr, "Invalid config:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintln(stderr, "  - "+line)
+		}
+		return exitCodeInvalidConfig
+	}
+	return 0
+}
+
+// mustLoadConfig parses args as config flags, returning the config, the
+// remaining positional arguments, and a func to load the config again from
+// the same layers. If the config can't be loaded or any of validateFields are
+// invalid (every field if none are given), the problems are printed and the
+// process exits with exitCodeInvalidConfig.
+func mustLoadConfig(
+	name string,
+	args []string,
+	validateFields ...string,
+) (*mainConfig, []string, func() (*mainConfig, configSources, error)) {
+	mainConfig, sources, args, load := mustParseConfigFlags(name, args, nil)
+	mustValidateConfig(mainConfig, sources, validateFields...)
+	return mainConfig, args, load
+}
+
+// mustParseConfigFlags is like mustLoadConfig, but registerFlags (if not nil)
+// can add the subcommand's own flags, and the config isn't validated so that
+// the subcommand can choose which fields it needs once its flags are parsed.
+func mustParseConfigFlags(
+	name string,
+	args []string,
+	registerFlags func(fs *flag.FlagSet),
+) (*mainConfig, configSources, []string, func() (*mainConfig, configSources, error)) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	if registerFlags != nil {
+		registerFlags(fs)
+	}
+	load := registerConfigFlags(fs)
+	_ = fs.Parse(args)
+	mainConfig, sources, err := load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(exitCodeInvalidConfig)
+	}
+	return mainConfig, sources, fs.Args(), load
+}
+
+// mustValidateConfig validates validateFields (every field if none are
+// given), printing the problems and exiting with exitCodeInvalidConfig if any
+// are invalid.
+func mustValidateConfig(mainConfig *mainConfig, sources configSources, validateFields ...string) {
+	if err := mainConfig.Validate(sources, validateFields...); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid config:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintln(os.Stderr, "  - "+line)
+		}
+		os.Exit(exitCodeInvalidConfig)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sawyerwatts/world-one/internal/common/features"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+var errConfigRequiresRestart = errors.New("config fields changed that require a restart")
+var errInvalidReloadedConfig = errors.New("reloaded config is invalid")
+
+// configChange is one field whose value differs between two configs.
+// Secrets are redacted.
+type configChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// configReloader holds the config that the running app uses and replaces it
+// when reloaded. Only fields tagged live may change; a reload changing any
+// other field is rejected as a whole so that a config is never half applied.
+//
+// Code reading live fields after startup must read them from Current each
+// time rather than holding on to a *mainConfig.
+type configReloader struct {
+	load      func() (*mainConfig, configSources, error)
+	slogLevel *slog.LevelVar
+	slogger   *slog.Logger
+
+	// mu serializes reloads, readers use current without locking.
+	mu      sync.Mutex
+	current atomic.Pointer[mainConfig]
+}
+
+// newConfigReloader applies mainConfig's live fields, and load will be
+// called to get the config on each reload.
+func newConfigReloader(
+	mainConfig *mainConfig,
+	load func() (*mainConfig, configSources, error),
+	slogLevel *slog.LevelVar,
+	slogger *slog.Logger,
+) *configReloader {
+	r := &configReloader{
+		load:      load,
+		slogLevel: slogLevel,
+		slogger:   slogger,
+	}
+	r.apply(mainConfig)
+	return r
+}
+
+// Current returns the config currently in use. It must not be modified.
+func (r *configReloader) Current() *mainConfig {
+	return r.current.Load()
+}
+
+// Reload loads and validates the config, and if only live fields changed,
+// applies them and returns the changes. The outcome is logged either way.
+func (r *configReloader) Reload(ctx context.Context) ([]configChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, changes, err := r.reload()
+	if err != nil {
+		r.slogger.ErrorContext(ctx, "Rejected config reload, keeping the current config", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	// The diff is logged before applying so that it isn't filtered out when
+	// the reload raises the slog level.
+	if len(changes) == 0 {
+		r.slogger.InfoContext(ctx, "Reloaded config, nothing changed")
+	} else {
+		attrs := make([]any, 0, len(changes))
+		for _, change := range changes {
+			attrs = append(attrs, slog.Group(change.Field, slog.String("from", change.From), slog.String("to", change.To)))
+		}
+		r.slogger.InfoContext(ctx, "Reloaded config", slog.Group("changes", attrs...))
+	}
+	r.apply(next)
+	return changes, nil
+}
+
+func (r *configReloader) reload() (*mainConfig, []configChange, error) {
+	next, sources, err := r.load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errInvalidReloadedConfig, err)
+	}
+	if err := next.Validate(sources); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errInvalidReloadedConfig, err)
+	}
+
+	changes, restartFields := diffConfig(r.Current(), next)
+	if len(restartFields) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", errConfigRequiresRestart, strings.Join(restartFields, ", "))
+	}
+	return next, changes, nil
+}
+
+func (r *configReloader) apply(mainConfig *mainConfig) {
+	// Validation already ensured the level can be parsed.
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(mainConfig.SlogLevel))
+	r.slogLevel.Set(level)
+	features.Set(mainConfig.FeatureFlags)
+	r.current.Store(mainConfig)
+}
+
+// diffConfig returns the fields that differ between from and to, and the
+// names of those which aren't live.
+func diffConfig(from *mainConfig, to *mainConfig) ([]configChange, []string) {
+	var changes []configChange
+	var restartFields []string
+	fromValue := reflect.ValueOf(from).Elem()
+	toValue := reflect.ValueOf(to).Elem()
+	for _, field := range configFields() {
+		fromField := fromValue.FieldByName(field.name).Interface()
+		toField := toValue.FieldByName(field.name).Interface()
+		if fromField == toField {
+			continue
+		}
+		change := configChange{
+			Field: field.name,
+			From:  fmt.Sprint(fromField),
+			To:    fmt.Sprint(toField),
+		}
+		if field.secret {
+			change.From = redact(change.From)
+			change.To = redact(change.To)
+		}
+		changes = append(changes, change)
+		if !field.live {
+			restartFields = append(restartFields, field.name)
+		}
+	}
+	return changes, restartFields
+}
+
+// newConfigReloadEndpoint reloads the config, responding with the applied
+// changes, or with problem details if the reload was rejected.
+func newConfigReloadEndpoint(reloader *configReloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changes, err := reloader.Reload(r.Context())
+		if err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, errConfigRequiresRestart) {
+				status = http.StatusConflict
+			}
+			_ = problem.Write(w, problem.New(status, err.Error(), ""))
+			return
+		}
+		if changes == nil {
+			changes = []configChange{}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"changes": changes})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sawyerwatts/world-one/internal/common/tracing"
+)
+
+// dbConfigFields are the config fields that subcommands connecting to the
+// database need.
+var dbConfigFields = []string{
+	"DBConnectionString",
+	"DBMaxConns",
+	"DBMinConns",
+	"DBMaxConnLifetimeSec",
+	"DBMaxConnIdleTimeSec",
+	"DBHealthCheckPeriodSec",
+	"DBStatementTimeoutMS",
+	"DBConnectWaitSec",
+}
+
+// connectDB creates the connection pool and, if configured to, waits for the
+// database to be available. It is for subcommands, so the caller must close
+// the pool.
+func connectDB(ctx context.Context, mainConfig *mainConfig, slogger *slog.Logger) (*pgxpool.Pool, error) {
+	dbPool, err := newDBPool(ctx, mainConfig)
+	if err != nil {
+		return nil, err
+	}
+	if mainConfig.DBConnectWaitSec > 0 {
+		if err := waitForDB(ctx, dbPool, time.Duration(mainConfig.DBConnectWaitSec)*time.Second, slogger); err != nil {
+			dbPool.Close()
+			return nil, err
+		}
+	}
+	return dbPool, nil
+}
+
+// newDBPool creates the connection pool from mainConfig. Connections are
+// established lazily, see waitForDB.
+func newDBPool(ctx context.Context, mainConfig *mainConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(mainConfig.DBConnectionString)
+	if err != nil {
+		// The error is not wrapped since it may contain the connection
+		// string's password.
+		return nil, errors.New("failed to parse the DB connection string")
+	}
+	poolConfig.MaxConns = int32(mainConfig.DBMaxConns)
+	poolConfig.MinConns = int32(mainConfig.DBMinConns)
+	poolConfig.MaxConnLifetime = time.Duration(mainConfig.DBMaxConnLifetimeSec) * time.Second
+	poolConfig.MaxConnIdleTime = time.Duration(mainConfig.DBMaxConnIdleTimeSec) * time.Second
+	poolConfig.HealthCheckPeriod = time.Duration(mainConfig.DBHealthCheckPeriodSec) * time.Second
+	poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(mainConfig.DBStatementTimeoutMS)
+	if mainConfig.DBApplicationName != "" {
+		poolConfig.ConnConfig.RuntimeParams["application_name"] = mainConfig.DBApplicationName
+	}
+	poolConfig.ConnConfig.Tracer = tracing.NewPgxTracer()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the DB pool: %w", err)
+	}
+	return pool, nil
+}
+
+// waitForDB pings the database until it responds or wait elapses, backing
+// off between attempts, so that a database that is briefly unavailable at
+// boot doesn't fail startup.
+func waitForDB(ctx context.Context, pool *pgxpool.Pool, wait time.Duration, slogger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				slogger.InfoContext(ctx, "Connected to the DB", slog.Int("attempt", attempt))
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("DB did not become available within %s: %w", wait, err)
+		}
+
+		slogger.WarnContext(ctx, "Failed to connect to the DB, retrying",
+			slog.Int("attempt", attempt),
+			slog.String("retryIn", backoff.String()),
+			slog.String("err", err.Error()))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("DB did not become available within %s: %w", wait, err)
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
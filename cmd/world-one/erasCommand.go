@@ -0,0 +1,294 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sawyerwatts/world-one/client"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/db"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+const erasUsage = `Usage: world-one eras [flags] <command>
+
+Commands:
+  list                print every era
+  current             print the current era
+  rollover NAME       end the current era and start a new one named NAME
+  schedule WHEN NAME  wait until WHEN, an RFC 3339 time or a duration such as
+                      90m, and then roll over to NAME; this runs in the
+                      foreground, so interrupting it cancels the rollover
+
+Flags:
+  -server URL  call a running server's web API rather than connecting to the
+               database directly
+  -json        print JSON rather than a table
+
+The config flags, such as -config, are accepted too. When connecting to the
+database directly, rollovers are timestamped with the real time, ignoring any
+time travel done on a running server.
+`
+
+// eraBackend is what the eras subcommand reads and rolls over eras with:
+// either the database or a running server.
+type eraBackend interface {
+	list(ctx context.Context) ([]eras.EraDTO, error)
+	current(ctx context.Context) (eras.EraDTO, error)
+	rollover(ctx context.Context, newEraName string) (newEra eras.EraDTO, prevEra *eras.EraDTO, _ error)
+}
+
+// runEras runs the eras subcommand and returns the process's exit code.
+func runEras(args []string, stdout io.Writer, stderr io.Writer) int {
+	var serverURL string
+	var asJSON bool
+	mainConfig, sources, args, _ := mustParseConfigFlags("eras", args, func(fs *flag.FlagSet) {
+		fs.Usage = func() { fmt.Fprint(stderr, erasUsage) }
+		fs.StringVar(&serverURL, "server", "", "the URL of a running server to call")
+		fs.BoolVar(&asJSON, "json", false, "print JSON rather than a table")
+	})
+	if len(args) == 0 {
+		fmt.Fprint(stderr, erasUsage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// The eras package logs each step at info, which would drown out the
+	// output, so only problems are logged.
+	slogger := slog.New(slog.NewJSONHandler(stderr, &slog.HandlerOptions{
+		AddSource: mainConfig.SlogIncludeSource,
+		Level:     slog.LevelWarn,
+	}))
+
+	var backend eraBackend
+	if serverURL != "" {
+		c, err := client.New(serverURL, client.Options{})
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid -server:", err)
+			return 2
+		}
+		backend = serverEraBackend{client: c}
+	} else {
+		mustValidateConfig(mainConfig, sources, dbConfigFields...)
+		dbPool, err := connectDB(ctx, mainConfig, slogger)
+		if err != nil {
+			fmt.Fprintln(stderr, "Failed to connect to the DB:", err)
+			return 1
+		}
+		defer dbPool.Close()
+		backend = dbEraBackend{store: eras.NewPgStore(dbPool), slogger: slogger, clock: clock.System{}}
+	}
+
+	return runErasCommand(ctx, backend, args, asJSON, stdout, stderr)
+}
+
+func runErasCommand(ctx context.Context, backend eraBackend, args []string, asJSON bool, stdout io.Writer, stderr io.Writer) int {
+	var err error
+	switch cmd, cmdArgs := args[0], args[1:]; {
+	case cmd == "list" && len(cmdArgs) == 0:
+		var all []eras.EraDTO
+		if all, err = backend.list(ctx); err == nil {
+			err = printEras(stdout, asJSON, all)
+		}
+	case cmd == "current" && len(cmdArgs) == 0:
+		var curr eras.EraDTO
+		if curr, err = backend.current(ctx); err == nil {
+			err = printEra(stdout, asJSON, curr)
+		}
+	case cmd == "rollover" && len(cmdArgs) == 1:
+		err = rolloverEras(ctx, backend, cmdArgs[0], asJSON, stdout)
+	case cmd == "schedule" && len(cmdArgs) == 2:
+		at, parseErr := parseWhen(cmdArgs[0], time.Now())
+		if parseErr != nil {
+			fmt.Fprintln(stderr, parseErr)
+			return 2
+		}
+		fmt.Fprintf(stderr, "Rolling over to %q at %s\n", cmdArgs[1], at.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Fprintln(stderr, "Canceled the scheduled rollover")
+			return 1
+		case <-timer.C:
+		}
+		err = rolloverEras(ctx, backend, cmdArgs[1], asJSON, stdout)
+	default:
+		fmt.Fprint(stderr, erasUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func rolloverEras(ctx context.Context, backend eraBackend, newEraName string, asJSON bool, stdout io.Writer) error {
+	newEra, prevEra, err := backend.rollover(ctx, newEraName)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		// This matches the web API's response.
+		return writeJSON(stdout, struct {
+			NewEraDTO  eras.EraDTO  `json:"newEraDTO"`
+			PrevEraDTO *eras.EraDTO `json:"prevEraDTO"`
+		}{newEra, prevEra})
+	}
+	if prevEra == nil {
+		return printEras(stdout, false, []eras.EraDTO{newEra})
+	}
+	return printEras(stdout, false, []eras.EraDTO{*prevEra, newEra})
+}
+
+// parseWhen parses an RFC 3339 time, or a duration from now.
+func parseWhen(when string, now time.Time) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, when); err == nil {
+		if at.Before(now) {
+			return time.Time{}, fmt.Errorf("%s is in the past, use rollover to roll over now", when)
+		}
+		return at, nil
+	}
+	d, err := time.ParseDuration(when)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("expected WHEN to be an RFC 3339 time or a non-negative duration, got %q", when)
+	}
+	return now.Add(d), nil
+}
+
+func printEra(w io.Writer, asJSON bool, era eras.EraDTO) error {
+	if asJSON {
+		return writeJSON(w, era)
+	}
+	return printEras(w, false, []eras.EraDTO{era})
+}
+
+func printEras(w io.Writer, asJSON bool, all []eras.EraDTO) error {
+	if asJSON {
+		return writeJSON(w, all)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTART\tEND")
+	for _, era := range all {
+		end := era.EndTime.Format(time.RFC3339)
+		if era.EndTime.Equal(common.UninitializedEndDate) {
+			end = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", era.ID, era.Name, era.StartTime.Format(time.RFC3339), end)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// dbEraBackend uses the database directly, like the web API's handlers.
+type dbEraBackend struct {
+	store   eras.Store
+	slogger *slog.Logger
+	clock   clock.Clock
+}
+
+func (b dbEraBackend) list(ctx context.Context) ([]eras.EraDTO, error) {
+	all, err := eras.MakeQueries(b.store, b.slogger).GetEras(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(all, func(a, b db.Era) int { return cmp.Compare(a.ID, b.ID) })
+	dtos := make([]eras.EraDTO, len(all))
+	for i, era := range all {
+		dtos[i] = eras.MakeEraDTO(era)
+	}
+	return dtos, nil
+}
+
+func (b dbEraBackend) current(ctx context.Context) (eras.EraDTO, error) {
+	curr, err := eras.MakeQueries(b.store, b.slogger).GetCurrEra(ctx)
+	if err != nil {
+		return eras.EraDTO{}, err
+	}
+	return eras.MakeEraDTO(curr), nil
+}
+
+func (b dbEraBackend) rollover(ctx context.Context, newEraName string) (eras.EraDTO, *eras.EraDTO, error) {
+	var newEra db.Era
+	var prevEra *db.Era
+	err := b.store.InSerializableTx(ctx, b.slogger, func(tx eras.Store) error {
+		var err error
+		newEra, prevEra, err = eras.Rollover(ctx, eras.MakeQueries(tx, b.slogger), tx, b.slogger, b.clock.Now().UTC(), newEraName)
+		return err
+	})
+	if err != nil {
+		return eras.EraDTO{}, nil, err
+	}
+	var prevEraDTO *eras.EraDTO
+	if prevEra != nil {
+		p := eras.MakeEraDTO(*prevEra)
+		prevEraDTO = &p
+	}
+	return eras.MakeEraDTO(newEra), prevEraDTO, nil
+}
+
+// serverEraBackend calls a running server's web API.
+type serverEraBackend struct {
+	client *client.Client
+}
+
+func (b serverEraBackend) list(ctx context.Context) ([]eras.EraDTO, error) {
+	var all []eras.EraDTO
+	for era, err := range b.client.Eras(ctx, 0) {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, eraDTOFromClient(era))
+	}
+	return all, nil
+}
+
+func (b serverEraBackend) current(ctx context.Context) (eras.EraDTO, error) {
+	curr, err := b.client.CurrentEra(ctx)
+	if err != nil {
+		return eras.EraDTO{}, err
+	}
+	return eraDTOFromClient(curr), nil
+}
+
+func (b serverEraBackend) rollover(ctx context.Context, newEraName string) (eras.EraDTO, *eras.EraDTO, error) {
+	result, err := b.client.Rollover(ctx, newEraName)
+	if err != nil {
+		return eras.EraDTO{}, nil, err
+	}
+	var prevEraDTO *eras.EraDTO
+	if result.Prev != nil {
+		p := eraDTOFromClient(*result.Prev)
+		prevEraDTO = &p
+	}
+	return eraDTOFromClient(result.New), prevEraDTO, nil
+}
+
+func eraDTOFromClient(era client.Era) eras.EraDTO {
+	return eras.EraDTO{
+		ID:         era.ID,
+		Name:       era.Name,
+		StartTime:  era.StartTime,
+		EndTime:    era.EndTime,
+		CreateTime: era.CreateTime,
+		UpdateTime: era.UpdateTime,
+	}
+}
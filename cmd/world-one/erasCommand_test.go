@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/client"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+// forEachEraBackend runs test against the database backend, using a MemStore,
+// and against the server backend, calling the real router.
+func forEachEraBackend(t *testing.T, test func(t *testing.T, backend eraBackend)) {
+	t.Run("db", func(t *testing.T) {
+		slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		test(t, dbEraBackend{store: eras.NewMemStore(clock.System{}), slogger: slogger, clock: clock.System{}})
+	})
+	t.Run("server", func(t *testing.T) {
+		_, c := newClientTestServer(t, nil)
+		test(t, serverEraBackend{client: c})
+	})
+}
+
+func TestErasCommand(t *testing.T) {
+	forEachEraBackend(t, func(t *testing.T, backend eraBackend) {
+		run := func(args ...string) (int, string) {
+			var stdout, stderr bytes.Buffer
+			code := runErasCommand(context.Background(), backend, args, true, &stdout, &stderr)
+			return code, stdout.String() + stderr.String()
+		}
+
+		if code, out := run("current"); code != 1 {
+			t.Fatalf("Expected current to fail without eras, got %d: %s", code, out)
+		}
+		if code, out := run("rollover", "First"); code != 0 {
+			t.Fatalf("Expected rollover to succeed, got %d: %s", code, out)
+		}
+		if code, out := run("schedule", "0s", "Second"); code != 0 {
+			t.Fatalf("Expected schedule to succeed, got %d: %s", code, out)
+		}
+		if code, out := run("rollover", "Second"); code != 1 || !strings.Contains(out, "duplicate") {
+			t.Fatalf("Expected a duplicate name to fail, got %d: %s", code, out)
+		}
+
+		code, out := run("current")
+		var curr eras.EraDTO
+		if code != 0 || json.Unmarshal([]byte(out), &curr) != nil || curr.Name != "Second" {
+			t.Fatalf("Expected the current era to be Second, got %d: %s", code, out)
+		}
+
+		code, out = run("list")
+		var all []eras.EraDTO
+		if code != 0 || json.Unmarshal([]byte(out), &all) != nil || len(all) != 2 || all[0].Name != "First" {
+			t.Fatalf("Expected First and Second, got %d: %s", code, out)
+		}
+
+		if code, _ := run("rollover"); code != 2 {
+			t.Fatalf("Expected a usage error, got %d", code)
+		}
+	})
+}
+
+func TestErasCommandPrintsTables(t *testing.T) {
+	forEachEraBackend(t, func(t *testing.T, backend eraBackend) {
+		var stdout bytes.Buffer
+		if code := runErasCommand(context.Background(), backend, []string{"rollover", "First"}, false, &stdout, io.Discard); code != 0 {
+			t.Fatalf("Expected rollover to succeed, got %d", code)
+		}
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "First") || !strings.HasSuffix(lines[1], "-") {
+			t.Fatalf("Unexpected table:\n%s", stdout.String())
+		}
+	})
+}
+
+func TestParseWhen(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		when    string
+		want    time.Time
+		wantErr bool
+	}{
+		{when: "90m", want: now.Add(90 * time.Minute)},
+		{when: "2024-03-02T00:00:00Z", want: now.Add(24 * time.Hour)},
+		{when: "2024-02-01T00:00:00Z", wantErr: true},
+		{when: "-1h", wantErr: true},
+		{when: "tomorrow", wantErr: true},
+	} {
+		got, err := parseWhen(tc.when, now)
+		if (err != nil) != tc.wantErr || !got.Equal(tc.want) {
+			t.Errorf("parseWhen(%q) = %s, %v; expected %s, error %t", tc.when, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestHealthCommandExitCodes(t *testing.T) {
+	env, router := newSpecTestRouter(t, specTestCase{})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if code := probeServer(context.Background(), server.URL, "ready", false, io.Discard, slogger); code != 1 {
+		t.Fatalf("Expected unevaluated readiness to exit 1, got %d", code)
+	}
+
+	rollover(t, env, "First")
+	env.runner.Evaluate(context.Background())
+	var stdout bytes.Buffer
+	if code := probeServer(context.Background(), server.URL, "all", true, &stdout, slogger); code != 0 {
+		t.Fatalf("Expected healthy checks to exit 0, got %d: %s", code, stdout.String())
+	}
+	var overview client.HealthOverview
+	if err := json.Unmarshal(stdout.Bytes(), &overview); err != nil || overview.Status != client.HealthStatusHealthy {
+		t.Fatalf("Expected a healthy overview, got %s", stdout.String())
+	}
+}
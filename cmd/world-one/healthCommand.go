@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sawyerwatts/world-one/client"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/eras"
+	"github.com/sawyerwatts/world-one/sql/migrations"
+)
+
+const healthUsage = `Usage: world-one health [flags] [all|live|ready|startup]
+
+Run the health checks once, print their results, and exit 1 if they're
+unhealthy (degraded is still 0), which is useful as a container probe. Only
+the results of the checks participating in the given probe are considered, or
+every check's if none is given.
+
+Flags:
+  -server URL  get a running server's latest results rather than running the
+               checks against the database
+  -history     include each check's recent results and transitions (only with
+               -server, since the checks otherwise run once)
+
+The config flags, such as -config, are accepted too.
+`
+
+var healthProbes = map[string]common.Probe{
+	"all":     0,
+	"live":    common.ProbeLiveness,
+	"ready":   common.ProbeReadiness,
+	"startup": common.ProbeStartup,
+}
+
+// newHealthChecks is every health check that serve runs.
+func newHealthChecks(mainConfig *mainConfig, dbPool *pgxpool.Pool, eraStore eras.Store) []common.HealthCheck {
+	expectedSchemaVersion, err := migrations.LatestVersion()
+	if err != nil {
+		panic(err)
+	}
+	checks := make([]common.HealthCheck, 0, 5)
+	checks = common.AppendDBHealthChecks(checks, dbPool, common.DBHealthCheckOptions{
+		ExpectedSchemaVersion:        expectedSchemaVersion,
+		AcquireWaitDegradedThreshold: time.Duration(mainConfig.DBAcquireWaitDegradedThresholdMS) * time.Millisecond,
+	})
+	return eras.AppendHealthChecks(checks, eraStore)
+}
+
+// runHealth runs the health subcommand and returns the process's exit code.
+func runHealth(args []string, stdout io.Writer, stderr io.Writer) int {
+	var serverURL string
+	var history bool
+	mainConfig, sources, args, _ := mustParseConfigFlags("health", args, func(fs *flag.FlagSet) {
+		fs.Usage = func() { fmt.Fprint(stderr, healthUsage) }
+		fs.StringVar(&serverURL, "server", "", "the URL of a running server to get the results from")
+		fs.BoolVar(&history, "history", false, "include each check's recent results and transitions")
+	})
+	probeName := "all"
+	if len(args) == 1 {
+		probeName = args[0]
+	}
+	probe, ok := healthProbes[probeName]
+	if len(args) > 1 || !ok {
+		fmt.Fprint(stderr, healthUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	slogger := slog.New(slog.NewJSONHandler(stderr, &slog.HandlerOptions{AddSource: mainConfig.SlogIncludeSource}))
+
+	if serverURL != "" {
+		return probeServer(ctx, serverURL, probeName, history, stdout, slogger)
+	}
+
+	mustValidateConfig(mainConfig, sources, append(dbConfigFields, "DBAcquireWaitDegradedThresholdMS")...)
+	// The DB isn't waited for since the checks report if it's unavailable.
+	dbPool, err := newDBPool(ctx, mainConfig)
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to create the DB pool", slog.String("err", err.Error()))
+		return 1
+	}
+	defer dbPool.Close()
+
+	runner := common.NewHealthCheckRunner(
+		newHealthChecks(mainConfig, dbPool, eras.NewPgStore(dbPool)),
+		time.Duration(mainConfig.HealthCheckIntervalMS)*time.Millisecond,
+		0,
+		clock.System{},
+		slogger)
+	runner.Evaluate(ctx)
+	status, err := runner.WriteOverview(stdout, probe, false)
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to write the results", slog.String("err", err.Error()))
+		return 1
+	}
+	return healthExitCode(string(status))
+}
+
+func probeServer(ctx context.Context, serverURL string, probeName string, history bool, stdout io.Writer, slogger *slog.Logger) int {
+	// A probe should answer quickly, so failures aren't retried.
+	c, err := client.New(serverURL, client.Options{MaxAttempts: 1})
+	if err != nil {
+		slogger.ErrorContext(ctx, "Invalid -server", slog.String("err", err.Error()))
+		return 2
+	}
+	var overview client.HealthOverview
+	if probeName == "all" {
+		overview, err = c.HealthChecks(ctx, history)
+	} else {
+		overview, err = c.Probe(ctx, client.Probe(probeName), history)
+	}
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to get the health checks", slog.String("err", err.Error()))
+		return 1
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(overview); err != nil {
+		slogger.ErrorContext(ctx, "Failed to write the results", slog.String("err", err.Error()))
+		return 1
+	}
+	return healthExitCode(string(overview.Status))
+}
+
+func healthExitCode(status string) int {
+	if status == string(common.HealthStatusUnhealthy) {
+		return 1
+	}
+	return 0
+}
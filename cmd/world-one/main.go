@@ -4,38 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "net/http/pprof" // BUG: how add auth to these endpoints?
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/metrics"
 	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/tracing"
 	"github.com/sawyerwatts/world-one/internal/eras"
 )
 
 // BUG: remember how to get pprof working (then put under the admin tag on oapi?)
 
-// TODO: impl paging on eras
-//	not because it's actually relevant there, but as an exercise
-
 // TODO: curr opr-level checklist task: README.md/assertions (just restart)
 // TODO: curr app-level checklist task: webApis.md/etags (just restart)
 // TODO: review security.md after auth is implemented
 
+const usage = `Usage: world-one [command] [flags] [args]
+
+Commands:
+  serve      run the web API (the default when no command is given)
+  eras       list, inspect, roll over, or schedule rollovers of eras
+  health     run the health checks once, exiting 1 if they're unhealthy
+  migrate    apply, roll back, or inspect the database migrations
+  seed       load fixtures, such as a fresh game's first era, into the database
+  config     show the resolved config
+  openapi    write the generated OpenAPI document
+
+Every command shares the same config layers, so -config, W1_* environment
+variables, and the config flags apply to each. Run world-one <command> -h for
+a command's usage.
+`
+
 func main() {
+	// Flags without a command are serve's, so that invocations predating
+	// the subcommands keep working.
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		serve(os.Args[1:])
+		return
+	}
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "serve":
+		serve(args)
+	case "eras":
+		os.Exit(runEras(args, os.Stdout, os.Stderr))
+	case "health":
+		os.Exit(runHealth(args, os.Stdout, os.Stderr))
+	case "migrate":
+		os.Exit(runMigrate(args, os.Stdout, os.Stderr))
+	case "seed":
+		os.Exit(runSeed(args, os.Stdout, os.Stderr))
+	case "config":
+		os.Exit(runConfig(args, os.Stdout, os.Stderr))
+	case "openapi":
+		os.Exit(runOpenAPI(args, os.Stdout, os.Stderr))
+	case "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+func serve(args []string) {
 	ctx := context.Background()
 
-	mainConfig := mustGetConfig()
+	mainConfig, _, load := mustLoadConfig("world-one", args)
 
 	{
+		// Validation already ensured the timezone can be loaded.
 		loc, err := time.LoadLocation(mainConfig.TimeZone)
 		if err != nil {
 			panic(fmt.Sprintf("Couldn't set timezone to '%s'", mainConfig.TimeZone))
@@ -43,61 +94,119 @@ func main() {
 		time.Local = loc
 	}
 
-	slogHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: mainConfig.SlogIncludeSource})
+	var slogLevel slog.LevelVar
+	slogHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource: mainConfig.SlogIncludeSource,
+		Level:     &slogLevel,
+	})
 	slogger := slog.New(slogHandler)
 	slog.SetDefault(slogger)
 
-	dbPool, err := pgxpool.New(context.Background(), mainConfig.DBConnectionString)
+	// Fields that can change while running must be read from the reloader
+	// rather than mainConfig.
+	reloader := newConfigReloader(mainConfig, load, &slogLevel, slogger)
+
+	shutdownTracing, err := tracing.Setup(ctx, mainConfig.TracingExporter, mainConfig.TracingOTLPEndpointURL)
 	if err != nil {
 		panic(err)
 	}
+
+	dbPool, err := newDBPool(ctx, mainConfig)
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to create the DB pool", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
 	defer dbPool.Close()
+	if mainConfig.DBConnectWaitSec > 0 {
+		if err := waitForDB(ctx, dbPool, time.Duration(mainConfig.DBConnectWaitSec)*time.Second, slogger); err != nil {
+			slogger.ErrorContext(ctx, "Failed to connect to the DB", slog.String("err", err.Error()))
+			os.Exit(1)
+		}
+	}
 
-	router := gin.Default()
-	{
-		// TODO: use gin.New() instead of gin.Default()?
-		//		update gin router to use slogger, esp w/ traceUUID
-		//		write own panic protection
-
-		router.Use(middleware.UseTraceUUIDAndSlogger(ctx, slogger))
-
-		{
-			checks := make([]common.HealthCheck, 0, 2)
-			checks = append(checks, common.HealthCheck{
-				Name: "Test DB Connectivity",
-				Check: func(c *gin.Context, _ *slog.Logger) common.HealthCheckResult {
-					_, err := dbPool.Exec(c, "select now()")
-					if err != nil {
-						return common.HealthCheckResult{
-							Status:  common.HealthStatusUnhealthy,
-							Payload: map[string]any{"err": err.Error()},
-						}
-					}
-					return common.HealthCheckResult{Status: common.HealthStatusHealthy}
-				},
-			})
-			checks = eras.AppendHealthChecks(checks, dbPool)
-			router.GET("/healthChecks", common.NewHealthChecksEndpoint(checks))
+	if mainConfig.AutoMigrate {
+		if err := autoMigrate(mainConfig, slogger); err != nil {
+			slogger.ErrorContext(ctx, "Failed to auto-migrate", slog.String("err", err.Error()))
+			os.Exit(1)
 		}
+	}
+
+	// The default registry's Go collector only reports a handful of runtime
+	// metrics, so it is swapped for one reporting GC, memory, and scheduler
+	// metrics too.
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.MustRegister(
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC,
+			collectors.MetricsMemory,
+			collectors.MetricsScheduler)),
+		metrics.NewPgxPoolCollector(dbPool))
 
-		v1 := router.Group("/v1")
-		v1.GET("", func(c *gin.Context) {
-			c.Header("Content-Type", "text/html; charset=utf-8")
-			c.HTML(http.StatusOK, "scalar-v1.html", gin.H{})
-		})
+	// Gin's own text logging is replaced by the access log and recovery
+	// middleware below, so only route registration warnings would remain.
+	gin.DefaultWriter = io.Discard
+	gin.DefaultErrorWriter = slog.NewLogLogger(slogHandler, slog.LevelError).Writer()
 
-		eras.Route(v1, dbPool)
-		router.StaticFile("/favicon.ico", filepath.Join(mainConfig.WebsiteDir, "favicon.ico"))
-		router.StaticFile("/open-api-v1.yml", filepath.Join(mainConfig.WebsiteDir, "open-api-v1.yml"))
-		router.LoadHTMLGlob(filepath.Join(mainConfig.WebsiteDir, "*.html"))
+	var shutdownState common.ShutdownState
+	eraStore := eras.NewPgStore(dbPool)
+	// The game clock can time travel in non-production builds, while
+	// operational timestamps such as health checks' always use the real time.
+	gameClock := clock.NewOffset(clock.System{})
+	healthCheckCtx, stopHealthChecks := context.WithCancel(ctx)
+
+	healthCheckRunner := common.NewHealthCheckRunner(
+		newHealthChecks(mainConfig, dbPool, eraStore),
+		time.Duration(mainConfig.HealthCheckIntervalMS)*time.Millisecond,
+		mainConfig.HealthCheckHistorySize,
+		clock.System{},
+		slogger)
+	go healthCheckRunner.Run(healthCheckCtx)
+
+	router, err := newRouter(ctx, routerDeps{
+		slogger:           slogger,
+		healthCheckRunner: healthCheckRunner,
+		shutdownState:     &shutdownState,
+		eraStore:          eraStore,
+		gameClock:         gameClock,
+		idempotencyCache: middleware.NewIdempotencyCache(
+			time.Duration(mainConfig.IdempotencyKeyTTLSec)*time.Second,
+			mainConfig.IdempotencyMaxKeys,
+			clock.System{}),
+		websiteDir: mainConfig.WebsiteDir,
+	})
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to create the router", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+
+	// Operational endpoints are served on the admin listener when one is
+	// configured so that they aren't exposed alongside the public API.
+	var adminServer *http.Server
+	if mainConfig.AdminAddr == "" {
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	} else {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", promhttp.Handler())
+		// Reloading is deliberately not exposed on the public listener, so
+		// without an admin listener only SIGHUP reloads the config.
+		adminMux.Handle("POST /config/reload", newConfigReloadEndpoint(reloader))
+		registerTimeTravel(adminMux, gameClock, slogger)
+		adminServer = &http.Server{
+			Addr:              mainConfig.AdminAddr,
+			Handler:           adminMux,
+			ReadHeaderTimeout: time.Duration(mainConfig.ReadHeaderTimeoutMS) * time.Millisecond,
+			ReadTimeout:       time.Duration(mainConfig.ReadTimeoutMS) * time.Millisecond,
+			IdleTimeout:       time.Duration(mainConfig.IdleTimeoutMS) * time.Millisecond,
+			MaxHeaderBytes:    1 << 20,
+			ErrorLog:          slog.NewLogLogger(slogHandler, slog.LevelError),
+		}
 	}
 
 	s := http.Server{
 		Addr: mainConfig.Addr,
-		Handler: http.TimeoutHandler(
-			router,
-			time.Duration(mainConfig.RequestTimeoutMS)*time.Millisecond,
-			fmt.Sprintf("The request timed out as it ran longer than %d milliseconds", mainConfig.RequestTimeoutMS)),
+		Handler: middleware.TimeoutHandler(router, func() time.Duration {
+			return time.Duration(reloader.Current().RequestTimeoutMS) * time.Millisecond
+		}),
 		ReadHeaderTimeout: time.Duration(mainConfig.ReadHeaderTimeoutMS) * time.Millisecond,
 		ReadTimeout:       time.Duration(mainConfig.ReadTimeoutMS) * time.Millisecond,
 		// WriteTimeout isn't configured since it closes the conn without
@@ -118,18 +227,55 @@ func main() {
 			exitCode = 1
 		}
 	}()
+	if adminServer != nil {
+		slogger.InfoContext(ctx, "Starting admin HTTP server", slog.String("addr", mainConfig.AdminAddr))
+		go func() {
+			err := adminServer.ListenAndServe()
+			if errors.Is(err, http.ErrServerClosed) {
+				slogger.InfoContext(ctx, "Shutting down admin server")
+			} else {
+				slog.Error("An bad error was returned by shut down admin server", slog.String("err", err.Error()))
+				exitCode = 1
+			}
+		}()
+	}
+
+	slogger.InfoContext(ctx, "Send hangup signals to reload the config")
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			_, _ = reloader.Reload(ctx)
+		}
+	}()
 
 	slogger.InfoContext(ctx, "Send interrupt or terminate signals to start gracefully shutting down the server")
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
-	slogger.InfoContext(ctx, "Received term or interrupt signal, will shutdown gracefully within a number of seconds", slog.Int("timeLimitSec", mainConfig.MaxGracefulShutdownSec))
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(mainConfig.MaxGracefulShutdownSec)*time.Second)
+	signal.Stop(reload)
+	shutdownState.Begin()
+	liveConfig := reloader.Current()
+	slogger.InfoContext(ctx, "Received term or interrupt signal, failing readiness to drain traffic", slog.Int("drainMS", liveConfig.ShutdownDrainMS))
+	time.Sleep(time.Duration(liveConfig.ShutdownDrainMS) * time.Millisecond)
+	slogger.InfoContext(ctx, "Will shutdown gracefully within a number of seconds", slog.Int("timeLimitSec", liveConfig.MaxGracefulShutdownSec))
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(liveConfig.MaxGracefulShutdownSec)*time.Second)
 	defer cancel()
 	if err := s.Shutdown(ctx); err != nil {
 		slogger.ErrorContext(ctx, "Server errored while shutting down", slog.String("err", err.Error()))
 		exitCode = 1
 	}
+	stopHealthChecks()
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			slogger.ErrorContext(ctx, "Admin server errored while shutting down", slog.String("err", err.Error()))
+			exitCode = 1
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slogger.ErrorContext(ctx, "Failed to flush spans while shutting down", slog.String("err", err.Error()))
+		exitCode = 1
+	}
 
 	os.Exit(exitCode)
 }
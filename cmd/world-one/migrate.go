@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/sawyerwatts/world-one/sql/migrations"
+)
+
+const migrateUsage = `Usage: world-one migrate [flags] <command>
+
+Commands:
+  up          apply every pending migration
+  down N|all  roll back N migrations, or every migration
+  status      print the current and expected schema versions
+  force V     set the schema version to V and clear the dirty flag, without
+              running any migrations (use after fixing a failed migration)
+`
+
+// runMigrate runs the migrate subcommand and returns the process's exit code.
+func runMigrate(args []string, stdout io.Writer, stderr io.Writer) int {
+	mainConfig, args, _ := mustLoadConfig("migrate", args, "DBConnectionString", "MigrationLockTimeoutSec")
+	if len(args) == 0 {
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+
+	slogger := slog.New(slog.NewJSONHandler(stderr, &slog.HandlerOptions{AddSource: mainConfig.SlogIncludeSource}))
+
+	m, err := migrations.New(mainConfig.DBConnectionString, slogger, time.Duration(mainConfig.MigrationLockTimeoutSec)*time.Second)
+	if err != nil {
+		slogger.Error("Failed to prepare migrations", slog.String("err", err.Error()))
+		return 1
+	}
+	defer m.Close()
+
+	switch cmd, cmdArgs := args[0], args[1:]; {
+	case cmd == "up" && len(cmdArgs) == 0:
+		err = m.Up()
+	case cmd == "down" && len(cmdArgs) == 1 && cmdArgs[0] == "all":
+		err = m.Down()
+	case cmd == "down" && len(cmdArgs) == 1:
+		n, convErr := strconv.Atoi(cmdArgs[0])
+		if convErr != nil || n < 1 {
+			fmt.Fprint(stderr, migrateUsage)
+			return 2
+		}
+		err = m.Steps(-n)
+	case cmd == "force" && len(cmdArgs) == 1:
+		v, convErr := strconv.Atoi(cmdArgs[0])
+		if convErr != nil || v < 0 {
+			fmt.Fprint(stderr, migrateUsage)
+			return 2
+		}
+		err = m.Force(v)
+	case cmd == "status" && len(cmdArgs) == 0:
+		return printMigrateStatus(m, stdout, slogger)
+	default:
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		slogger.Info("There were no migrations to apply")
+		return 0
+	}
+	if err != nil {
+		slogger.Error("Migration failed", slog.String("command", args[0]), slog.String("err", err.Error()))
+		return 1
+	}
+	slogger.Info("Migration succeeded", slog.String("command", args[0]))
+	return printMigrateStatus(m, stdout, slogger)
+}
+
+func printMigrateStatus(m *migrate.Migrate, stdout io.Writer, slogger *slog.Logger) int {
+	expected, err := migrations.LatestVersion()
+	if err != nil {
+		slogger.Error("Failed to determine the expected schema version", slog.String("err", err.Error()))
+		return 1
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Fprintf(stdout, "version: none\ndirty: false\nexpected: %d\n", expected)
+		return 0
+	}
+	if err != nil {
+		slogger.Error("Failed to retrieve the schema version", slog.String("err", err.Error()))
+		return 1
+	}
+	fmt.Fprintf(stdout, "version: %d\ndirty: %t\nexpected: %d\n", version, dirty, expected)
+	return 0
+}
+
+// autoMigrate applies every pending migration; it is used at startup when
+// configured to.
+func autoMigrate(mainConfig *mainConfig, slogger *slog.Logger) error {
+	m, err := migrations.New(mainConfig.DBConnectionString, slogger, time.Duration(mainConfig.MigrationLockTimeoutSec)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	slogger.Info("Applying any pending migrations")
+	err = m.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		slogger.Info("There were no migrations to apply")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	slogger.Info("Applied pending migrations")
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+)
+
+const openAPIUsage = `Usage: world-one openapi [-o file]
+
+Write the OpenAPI document generated from the routes to stdout, or to file.
+The checked in copy is regenerated with:
+
+  go run ./cmd/world-one openapi -o website/open-api-v1.yml
+`
+
+// runOpenAPI runs the openapi subcommand and returns the process's exit code.
+func runOpenAPI(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, openAPIUsage) }
+	out := fs.String("o", "", "the file to write the document to")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return 2
+	}
+
+	// Keeps gin from logging each route as it's registered.
+	gin.SetMode(gin.ReleaseMode)
+	doc, err := buildOpenAPISpec()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	specYAML, err := openapi.MarshalYAML(doc)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(specYAML)
+	} else {
+		err = os.WriteFile(*out, specYAML, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
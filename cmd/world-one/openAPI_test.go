@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"github.com/sawyerwatts/world-one/internal/db"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+const specPath = "../../website/open-api-v1.yml"
+
+// specServer is the first server in the spec, which requests must target to
+// be matched to an operation.
+const specServer = "http://localhost:8080"
+
+// undocumentedRoutes are served by the router but intentionally absent from
+// the spec.
+var undocumentedRoutes = []string{
+	"GET /v1",
+	"GET /favicon.ico",
+	"HEAD /favicon.ico",
+	"GET /open-api-v1.yml",
+	"HEAD /open-api-v1.yml",
+}
+
+type specTestEnv struct {
+	store         *eras.MemStore
+	runner        *common.HealthCheckRunner
+	shutdownState *common.ShutdownState
+}
+
+type specTestCase struct {
+	name   string
+	method string
+	target string
+	// setup prepares the environment before the request.
+	setup func(t *testing.T, env *specTestEnv)
+	// store overrides the era store given to the router.
+	store func(env *specTestEnv) eras.Store
+	// timeout, if set, wraps the router in middleware.TimeoutHandler.
+	timeout time.Duration
+	header  http.Header
+	// invalidRequest skips validating the request against the spec, for
+	// cases testing how invalid requests are handled.
+	invalidRequest bool
+	wantStatus     int
+}
+
+func specTestCases() []specTestCase {
+	seedEra := func(name string) func(t *testing.T, env *specTestEnv) {
+		return func(t *testing.T, env *specTestEnv) {
+			rollover(t, env, name)
+		}
+	}
+	evaluate := func(setup func(t *testing.T, env *specTestEnv)) func(t *testing.T, env *specTestEnv) {
+		return func(t *testing.T, env *specTestEnv) {
+			if setup != nil {
+				setup(t, env)
+			}
+			env.runner.Evaluate(context.Background())
+		}
+	}
+
+	return []specTestCase{
+		{name: "no eras", method: http.MethodGet, target: "/v1/eras", wantStatus: http.StatusOK},
+		{name: "eras", method: http.MethodGet, target: "/v1/eras", setup: seedEra("First"), wantStatus: http.StatusOK},
+		{
+			name:   "eras page",
+			method: http.MethodGet,
+			target: "/v1/eras?pageSize=1&after=1",
+			setup: func(t *testing.T, env *specTestEnv) {
+				seedEra("First")(t, env)
+				seedEra("Second")(t, env)
+				seedEra("Third")(t, env)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "eras page too large",
+			method:         http.MethodGet,
+			target:         "/v1/eras?pageSize=1000",
+			invalidRequest: true,
+			wantStatus:     http.StatusBadRequest,
+		},
+		{
+			name:       "eras store failure",
+			method:     http.MethodGet,
+			target:     "/v1/eras",
+			store:      func(env *specTestEnv) eras.Store { return failingStore{env.store} },
+			wantStatus: http.StatusInternalServerError,
+		},
+		{name: "no current era", method: http.MethodGet, target: "/v1/eras/current", wantStatus: http.StatusInternalServerError},
+		{name: "current era", method: http.MethodGet, target: "/v1/eras/current", setup: seedEra("First"), wantStatus: http.StatusOK},
+		{
+			name:       "current era timeout",
+			method:     http.MethodGet,
+			target:     "/v1/eras/current",
+			store:      func(env *specTestEnv) eras.Store { return slowStore{env.store} },
+			timeout:    10 * time.Millisecond,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{name: "first rollover", method: http.MethodPost, target: "/v1/eras/rollover?newEraName=First", wantStatus: http.StatusCreated},
+		{
+			name:       "rollover",
+			method:     http.MethodPost,
+			target:     "/v1/eras/rollover?newEraName=Second",
+			setup:      seedEra("First"),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "idempotent rollover",
+			method:     http.MethodPost,
+			target:     "/v1/eras/rollover?newEraName=First",
+			header:     http.Header{"Idempotency-Key": {"a8b0c6f1"}},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:           "rollover without name",
+			method:         http.MethodPost,
+			target:         "/v1/eras/rollover",
+			invalidRequest: true,
+			wantStatus:     http.StatusBadRequest,
+		},
+		{
+			name:       "rollover duplicate name",
+			method:     http.MethodPost,
+			target:     "/v1/eras/rollover?newEraName=First",
+			setup:      seedEra("First"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "rollover conflict",
+			method:     http.MethodPost,
+			target:     "/v1/eras/rollover?newEraName=First",
+			store:      func(env *specTestEnv) eras.Store { return conflictingStore{env.store} },
+			wantStatus: http.StatusConflict,
+		},
+		{name: "health checks unevaluated", method: http.MethodGet, target: "/healthChecks", wantStatus: http.StatusServiceUnavailable},
+		{
+			name:       "health checks healthy",
+			method:     http.MethodGet,
+			target:     "/healthChecks?history=true",
+			setup:      evaluate(seedEra("First")),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "health checks invalid history",
+			method:         http.MethodGet,
+			target:         "/healthChecks?history=sometimes",
+			invalidRequest: true,
+			wantStatus:     http.StatusBadRequest,
+		},
+		{name: "liveness unevaluated", method: http.MethodGet, target: "/health/live", wantStatus: http.StatusOK},
+		{name: "readiness unevaluated", method: http.MethodGet, target: "/health/ready", wantStatus: http.StatusServiceUnavailable},
+		{name: "startup unevaluated", method: http.MethodGet, target: "/health/startup", wantStatus: http.StatusServiceUnavailable},
+		{name: "liveness", method: http.MethodGet, target: "/health/live", setup: evaluate(nil), wantStatus: http.StatusOK},
+		{name: "readiness unhealthy", method: http.MethodGet, target: "/health/ready", setup: evaluate(nil), wantStatus: http.StatusServiceUnavailable},
+		{
+			name:       "readiness healthy",
+			method:     http.MethodGet,
+			target:     "/health/ready?history=true",
+			setup:      evaluate(seedEra("First")),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "readiness shutting down",
+			method: http.MethodGet,
+			target: "/health/ready",
+			setup: func(t *testing.T, env *specTestEnv) {
+				seedEra("First")(t, env)
+				env.runner.Evaluate(context.Background())
+				env.shutdownState.Begin()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{name: "startup", method: http.MethodGet, target: "/health/startup", setup: evaluate(nil), wantStatus: http.StatusOK},
+	}
+}
+
+// TestHandlersMatchOpenAPISpec exercises every documented operation against
+// the real router, validating the requests and responses against the spec.
+func TestHandlersMatchOpenAPISpec(t *testing.T) {
+	doc, specRouter := loadSpec(t)
+
+	covered := make(map[string]bool)
+	for _, tc := range specTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			env, router := newSpecTestRouter(t, tc)
+			if tc.setup != nil {
+				tc.setup(t, env)
+			}
+			var handler http.Handler = router
+			if tc.timeout > 0 {
+				handler = middleware.TimeoutHandler(router, func() time.Duration { return tc.timeout })
+			}
+
+			req := httptest.NewRequest(tc.method, specServer+tc.target, nil)
+			for name, values := range tc.header {
+				req.Header[name] = values
+			}
+			route, pathParams, err := specRouter.FindRoute(req)
+			if err != nil {
+				t.Fatalf("%s %s is not documented: %v", tc.method, tc.target, err)
+			}
+			covered[tc.method+" "+route.Path] = true
+			reqInput := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+			}
+			if !tc.invalidRequest {
+				if err := openapi3filter.ValidateRequest(context.Background(), reqInput); err != nil {
+					t.Fatalf("The request does not match the spec: %v", err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("Expected %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: reqInput,
+				Status:                 w.Code,
+				Header:                 w.Header(),
+				Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			})
+			if err != nil {
+				t.Fatalf("The response does not match the spec: %v\n%s", err, w.Body.String())
+			}
+		})
+	}
+
+	for path, pathItem := range doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			if !covered[method+" "+path] {
+				t.Errorf("%s %s is documented but not exercised by any test case", method, path)
+			}
+		}
+	}
+}
+
+// TestCheckedInSpecIsGenerated fails when the routes change without
+// regenerating the checked in document.
+func TestCheckedInSpecIsGenerated(t *testing.T) {
+	doc, err := buildOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := openapi.MarshalYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkedIn, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, checkedIn) {
+		t.Fatalf("%s is stale, regenerate it with: go run ./cmd/world-one openapi -o website/open-api-v1.yml", specPath)
+	}
+}
+
+// TestServedSpecIsGenerated ensures the router serves the same document that
+// is checked in.
+func TestServedSpecIsGenerated(t *testing.T) {
+	_, router := newSpecTestRouter(t, specTestCase{})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/open-api-v1.yml", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	checkedIn, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Body.Bytes(), checkedIn) {
+		t.Fatalf("The served document differs from %s", specPath)
+	}
+}
+
+// TestRoutesAreDocumented fails when a route is added to the router without
+// being added to the spec.
+func TestRoutesAreDocumented(t *testing.T) {
+	doc, _ := loadSpec(t)
+	_, router := newSpecTestRouter(t, specTestCase{})
+
+	ginParam := regexp.MustCompile(`[:*](\w+)`)
+	var undocumented []string
+	for _, r := range router.Routes() {
+		route := r.Method + " " + r.Path
+		if strings.HasPrefix(route, "GET /metrics") || contains(undocumentedRoutes, route) {
+			continue
+		}
+		pathItem := doc.Paths.Find(ginParam.ReplaceAllString(r.Path, "{$1}"))
+		if pathItem == nil || pathItem.GetOperation(r.Method) == nil {
+			undocumented = append(undocumented, route)
+		}
+	}
+	sort.Strings(undocumented)
+	if len(undocumented) > 0 {
+		t.Fatalf("Routes are missing from %s:\n%s", specPath, strings.Join(undocumented, "\n"))
+	}
+}
+
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", specPath, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		t.Fatalf("%s is invalid: %v", specPath, err)
+	}
+	specRouter, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Failed to route %s: %v", specPath, err)
+	}
+	return doc, specRouter
+}
+
+func newSpecTestRouter(t *testing.T, tc specTestCase) (*specTestEnv, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env := &specTestEnv{
+		store:         eras.NewMemStore(clock.System{}),
+		shutdownState: &common.ShutdownState{},
+	}
+	env.runner = common.NewHealthCheckRunner(eras.AppendHealthChecks(nil, env.store), time.Minute, 5, clock.System{}, slogger)
+
+	var store eras.Store = env.store
+	if tc.store != nil {
+		store = tc.store(env)
+	}
+	router, err := newRouter(context.Background(), routerDeps{
+		slogger:           slogger,
+		healthCheckRunner: env.runner,
+		shutdownState:     env.shutdownState,
+		eraStore:          store,
+		gameClock:         clock.System{},
+		idempotencyCache:  middleware.NewIdempotencyCache(time.Minute, 100, clock.System{}),
+		websiteDir:        "../../website",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, router
+}
+
+func rollover(t *testing.T, env *specTestEnv, name string) {
+	t.Helper()
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, _, err := eras.Rollover(context.Background(), eras.MakeQueries(env.store, slogger), env.store, slogger, time.Now().UTC(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// failingStore fails every read.
+type failingStore struct {
+	eras.Store
+}
+
+func (failingStore) GetErasPage(context.Context, db.GetErasPageParams) ([]db.Era, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+// slowStore blocks reads until the request times out.
+type slowStore struct {
+	eras.Store
+}
+
+func (slowStore) GetCurrEra(ctx context.Context) (db.Era, error) {
+	<-ctx.Done()
+	return db.Era{}, ctx.Err()
+}
+
+// conflictingStore's transactions always conflict.
+type conflictingStore struct {
+	eras.Store
+}
+
+func (conflictingStore) InSerializableTx(context.Context, *slog.Logger, func(tx eras.Store) error) error {
+	return common.ErrTxConflict
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+// routerDeps are what the public API's handlers depend on.
+type routerDeps struct {
+	slogger           *slog.Logger
+	healthCheckRunner *common.HealthCheckRunner
+	shutdownState     *common.ShutdownState
+	eraStore          eras.Store
+	gameClock         clock.Clock
+	idempotencyCache  *middleware.IdempotencyCache
+	websiteDir        string
+}
+
+// newRouter creates the router for the public API. Its OpenAPI document is
+// generated from the routes, served at /open-api-v1.yml, and requests are
+// validated against it. The caller is responsible for running the health
+// checks.
+func newRouter(ctx context.Context, deps routerDeps) (*gin.Engine, error) {
+	router := gin.New()
+
+	// This allows handlers to pass the *gin.Context as a context.Context
+	// while still honoring the request's deadline and span.
+	router.ContextWithFallback = true
+
+	// Every error response is a problem, including gin's own 404s and 405s.
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(problem.NoRoute)
+	router.NoMethod(problem.NoMethod)
+
+	requestValidator := &middleware.RequestValidator{}
+	router.Use(
+		middleware.UseAccessLog(deps.slogger),
+		middleware.UseMetrics(),
+		middleware.UseTracing(),
+		middleware.UseRecovery(deps.slogger),
+		middleware.UseTraceUUIDAndSlogger(ctx, deps.slogger),
+		requestValidator.Handle,
+		middleware.UseIdempotency(deps.idempotencyCache))
+
+	spec := newOpenAPISpec()
+	registerAPI(openapi.NewGroup(spec, &router.RouterGroup), deps)
+	doc, err := spec.Build()
+	if err != nil {
+		return nil, err
+	}
+	if err := requestValidator.Load(doc); err != nil {
+		return nil, err
+	}
+	specYAML, err := openapi.MarshalYAML(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the OpenAPI document: %w", err)
+	}
+
+	router.GET("/v1", func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.HTML(http.StatusOK, "scalar-v1.html", gin.H{})
+	})
+	serveSpec := func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", specYAML)
+	}
+	router.GET("/open-api-v1.yml", serveSpec)
+	router.HEAD("/open-api-v1.yml", serveSpec)
+	router.StaticFile("/favicon.ico", filepath.Join(deps.websiteDir, "favicon.ico"))
+	router.LoadHTMLGlob(filepath.Join(deps.websiteDir, "*.html"))
+
+	return router, nil
+}
+
+// newOpenAPISpec describes the public API as a whole; registerAPI adds its
+// operations.
+func newOpenAPISpec() *openapi.Spec {
+	return openapi.NewSpec(openapi.Info{
+		Title: "World One",
+		Description: "This is a lil project for Sawyer.\n\n" +
+			"A timestamp with value 2200-01-01T00:00:00Z will indicate an unconfigured (end) value.\n",
+		Version: "1.0.0",
+		Servers: []string{"http://localhost:8080"},
+		Tags: []openapi.Tag{
+			{Name: "Eras", Description: "Eras control configs and allow for soft-resets of the game state."},
+			{Name: "Operations", Description: "These endpoints are for IT operations."},
+		},
+	})
+}
+
+// registerAPI registers the documented routes of the public API.
+func registerAPI(api *openapi.Group, deps routerDeps) {
+	common.RouteHealthChecks(api, deps.healthCheckRunner, deps.shutdownState)
+	eras.Route(api.Group("/v1"), deps.eraStore, deps.gameClock)
+}
+
+// buildOpenAPISpec generates the public API's OpenAPI document without
+// anything to serve it with.
+func buildOpenAPISpec() (*openapi3.T, error) {
+	spec := newOpenAPISpec()
+	registerAPI(openapi.NewGroup(spec, &gin.New().RouterGroup), routerDeps{})
+	return spec.Build()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+// TestErrorResponsesAreProblems covers the error responses that don't come
+// from a documented operation, so aren't covered by the spec tests.
+func TestErrorResponsesAreProblems(t *testing.T) {
+	_, router := newSpecTestRouter(t, specTestCase{})
+	for _, tc := range []struct {
+		name       string
+		method     string
+		target     string
+		traceUUID  string
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "unknown path", method: http.MethodGet, target: "/v1/nope", wantStatus: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodDelete, target: "/v1/eras/current", wantStatus: http.StatusMethodNotAllowed, wantAllow: http.MethodGet},
+		{name: "unparsable trace UUID", method: http.MethodGet, target: "/v1/eras", traceUUID: "not-a-uuid", wantStatus: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			if tc.traceUUID != "" {
+				req.Header.Set(middleware.TraceUUIDHeader, tc.traceUUID)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("Expected %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != problem.ContentType {
+				t.Fatalf("Expected a problem, got %s: %s", contentType, rec.Body.String())
+			}
+			if allow := rec.Header().Get("Allow"); allow != tc.wantAllow {
+				t.Fatalf("Expected Allow %q, got %q", tc.wantAllow, allow)
+			}
+			var details problem.Details
+			if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+				t.Fatal(err)
+			}
+			headerUUID := rec.Header().Get(middleware.TraceUUIDHeader)
+			if _, err := uuid.Parse(headerUUID); err != nil || details.TraceUUID != headerUUID {
+				t.Fatalf("Expected the problem's trace UUID to match the header %q, got %q", headerUUID, details.TraceUUID)
+			}
+			if details.Status != tc.wantStatus {
+				t.Fatalf("Expected the problem's status to be %d, got %d", tc.wantStatus, details.Status)
+			}
+		})
+	}
+}
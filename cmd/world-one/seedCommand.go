@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/eras"
+	"github.com/sawyerwatts/world-one/internal/seed"
+)
+
+const seedUsage = `Usage: world-one seed [flags] [-scenario NAME | FILE]
+
+Load a fixture into the database, either a built in scenario or a YAML or JSON
+file. Seeding is idempotent: what already exists (matched by name) is skipped,
+so seeding twice is harmless. The whole fixture is seeded in one transaction.
+
+Flags:
+  -scenario NAME  seed a built in scenario, see -list
+  -list           list the built in scenarios
+  -reset          delete the existing data first; this is destructive
+
+The config flags, such as -config, are accepted too.
+
+An example fixture, whose times are RFC 3339 times or durations relative to
+now, and where the era without an end is the current era:
+
+  eras:
+    - name: The First Era
+      start: 2024-01-01T00:00:00Z
+      end: -72h
+    - name: The Second Era
+      start: -72h
+`
+
+// runSeed runs the seed subcommand and returns the process's exit code.
+func runSeed(args []string, stdout io.Writer, stderr io.Writer) int {
+	var scenario string
+	var list, reset bool
+	mainConfig, sources, args, _ := mustParseConfigFlags("seed", args, func(fs *flag.FlagSet) {
+		fs.Usage = func() { fmt.Fprint(stderr, seedUsage) }
+		fs.StringVar(&scenario, "scenario", "", "the built in scenario to seed")
+		fs.BoolVar(&list, "list", false, "list the built in scenarios")
+		fs.BoolVar(&reset, "reset", false, "delete the existing data first")
+	})
+	if list {
+		for _, name := range seed.Scenarios() {
+			fixture, err := seed.Scenario(name)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s\t%s\n", name, strings.TrimSpace(fixture.Description))
+		}
+		return 0
+	}
+	if (scenario == "") == (len(args) == 0) || len(args) > 1 {
+		fmt.Fprint(stderr, seedUsage)
+		return 2
+	}
+
+	var fixture seed.Fixture
+	var err error
+	if scenario != "" {
+		fixture, err = seed.Scenario(scenario)
+	} else {
+		fixture, err = parseFixtureFile(args[0])
+	}
+	if err == nil {
+		err = fixture.Validate()
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	slogger := slog.New(slog.NewJSONHandler(stderr, &slog.HandlerOptions{AddSource: mainConfig.SlogIncludeSource}))
+	mustValidateConfig(mainConfig, sources, dbConfigFields...)
+	dbPool, err := connectDB(ctx, mainConfig, slogger)
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to connect to the DB", slog.String("err", err.Error()))
+		return 1
+	}
+	defer dbPool.Close()
+
+	report, err := seed.Seed(ctx, eras.NewPgStore(dbPool), slogger, clock.System{}.Now(), fixture, seed.Options{Reset: reset})
+	if err != nil {
+		slogger.ErrorContext(ctx, "Failed to seed", slog.String("err", err.Error()))
+		return 1
+	}
+	fmt.Fprintf(stdout, "eras: %d deleted, %d inserted, %d already present\n",
+		report.ErasDeleted, report.ErasInserted, report.ErasSkipped)
+	return 0
+}
+
+func parseFixtureFile(name string) (seed.Fixture, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return seed.Fixture{}, fmt.Errorf("failed to open the fixture: %w", err)
+	}
+	defer f.Close()
+	fixture, err := seed.Parse(f)
+	if err != nil {
+		return seed.Fixture{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return fixture, nil
+}
@@ -0,0 +1,69 @@
+//go:build !production
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+// registerTimeTravel adds endpoints for shifting the game clock, which is
+// useful for playtesting era transitions. They are excluded from builds with
+// the production tag.
+//
+//   - GET /clock returns the game clock's time and offset.
+//   - POST /clock/travel?to=<RFC 3339 time> or ?by=<Go duration> shifts it.
+//   - DELETE /clock/travel returns it to the real time.
+func registerTimeTravel(mux *http.ServeMux, gameClock *clock.Offset, slogger *slog.Logger) {
+	slogger.Warn("Time travel endpoints are enabled since this is not a production build")
+
+	writeClock := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"now":    gameClock.Now().UTC().Format(time.RFC3339Nano),
+			"offset": gameClock.Offset().String(),
+		})
+	}
+
+	mux.HandleFunc("GET /clock", func(w http.ResponseWriter, r *http.Request) {
+		writeClock(w)
+	})
+
+	mux.HandleFunc("POST /clock/travel", func(w http.ResponseWriter, r *http.Request) {
+		to, by := r.URL.Query().Get("to"), r.URL.Query().Get("by")
+		switch {
+		case to != "" && by == "":
+			t, err := time.Parse(time.RFC3339Nano, to)
+			if err != nil {
+				_ = problem.Write(w, problem.New(http.StatusBadRequest, "Query parameter to is not an RFC 3339 time: "+err.Error(), ""))
+				return
+			}
+			gameClock.TravelTo(t)
+		case by != "" && to == "":
+			d, err := time.ParseDuration(by)
+			if err != nil {
+				_ = problem.Write(w, problem.New(http.StatusBadRequest, "Query parameter by is not a duration: "+err.Error(), ""))
+				return
+			}
+			gameClock.TravelBy(d)
+		default:
+			_ = problem.Write(w, problem.New(http.StatusBadRequest, "Expected exactly one of the query parameters to or by", ""))
+			return
+		}
+		slogger.WarnContext(r.Context(), "Time traveled",
+			slog.Time("now", gameClock.Now()),
+			slog.String("offset", gameClock.Offset().String()))
+		writeClock(w)
+	})
+
+	mux.HandleFunc("DELETE /clock/travel", func(w http.ResponseWriter, r *http.Request) {
+		gameClock.Reset()
+		slogger.InfoContext(r.Context(), "Returned from time travel")
+		writeClock(w)
+	})
+}
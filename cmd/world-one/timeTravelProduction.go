@@ -0,0 +1,13 @@
+//go:build production
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+)
+
+// registerTimeTravel is a no-op since production builds can't time travel.
+func registerTimeTravel(*http.ServeMux, *clock.Offset, *slog.Logger) {}
@@ -1,7 +0,0 @@
-package common
-
-const PsqlErrorMessageNoRows = "no rows in result set"
-
-// https://www.postgresql.org/docs/current/errcodes-appendix.html
-
-const PgErrorCodeUniqueViolation = "23505"
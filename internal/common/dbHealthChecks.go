@@ -0,0 +1,170 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const pgErrorCodeUndefinedTable = "42P01"
+
+type DBHealthCheckOptions struct {
+	// ExpectedSchemaVersion is the golang-migrate version the binary was
+	// built for.
+	ExpectedSchemaVersion uint
+	// AcquireWaitDegradedThreshold is the average time acquiring a pool
+	// connection may take (since the last evaluation) before the pool is
+	// considered saturated.
+	AcquireWaitDegradedThreshold time.Duration
+}
+
+// AppendDBHealthChecks appends checks of the database's connectivity, schema
+// version, timezone, and the pool's saturation.
+func AppendDBHealthChecks(checks []HealthCheck, dbPool *pgxpool.Pool, opts DBHealthCheckOptions) []HealthCheck {
+	poolCheck := poolSaturationCheck{
+		dbPool:    dbPool,
+		threshold: opts.AcquireWaitDegradedThreshold,
+	}
+
+	return append(checks,
+		HealthCheck{
+			Name:              "Test DB Connectivity",
+			Probes:            ProbeReadiness | ProbeStartup,
+			Timeout:           2 * time.Second,
+			Critical:          true,
+			DegradedThreshold: 500 * time.Millisecond,
+			Check: func(ctx context.Context, _ *slog.Logger) HealthCheckResult {
+				_, err := dbPool.Exec(ctx, "select now()")
+				if err != nil {
+					return HealthCheckResult{
+						Status:  HealthStatusUnhealthy,
+						Payload: map[string]any{"err": err.Error()},
+					}
+				}
+				return HealthCheckResult{Status: HealthStatusHealthy}
+			},
+		},
+		HealthCheck{
+			Name:     "Assert DB schema version",
+			Probes:   ProbeReadiness | ProbeStartup,
+			Timeout:  2 * time.Second,
+			Critical: true,
+			Check: func(ctx context.Context, _ *slog.Logger) HealthCheckResult {
+				return checkSchemaVersion(ctx, dbPool, opts.ExpectedSchemaVersion)
+			},
+		},
+		HealthCheck{
+			Name:    "Assert DB timezone is UTC",
+			Probes:  ProbeReadiness | ProbeStartup,
+			Timeout: 2 * time.Second,
+			Check: func(ctx context.Context, _ *slog.Logger) HealthCheckResult {
+				var timeZone string
+				if err := dbPool.QueryRow(ctx, "show timezone").Scan(&timeZone); err != nil {
+					return HealthCheckResult{
+						Status:  HealthStatusUnhealthy,
+						Payload: map[string]any{"err": err.Error()},
+					}
+				}
+				// The README requires the DB's timezone to be GMT; these
+				// are all equivalent.
+				switch timeZone {
+				case "GMT", "UTC", "Etc/GMT", "Etc/UTC", "Etc/UCT", "UCT", "Zulu", "Etc/Zulu", "Universal", "Etc/Universal":
+					return HealthCheckResult{Status: HealthStatusHealthy}
+				}
+				return HealthCheckResult{
+					Status:  HealthStatusUnhealthy,
+					Payload: map[string]any{"timeZone": timeZone, "err": "the DB's timezone must be GMT"},
+				}
+			},
+		},
+		HealthCheck{
+			Name:   "Assert DB pool is not saturated",
+			Probes: ProbeReadiness,
+			Check:  poolCheck.check,
+		})
+}
+
+func checkSchemaVersion(ctx context.Context, dbPool *pgxpool.Pool, expected uint) HealthCheckResult {
+	var version int64
+	var dirty bool
+	err := dbPool.QueryRow(ctx, "select version, dirty from schema_migrations limit 1").Scan(&version, &dirty)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgErrorCodeUndefinedTable {
+			return HealthCheckResult{
+				Status:  HealthStatusUnhealthy,
+				Payload: map[string]any{"expectedVersion": expected, "err": "no migrations have been applied"},
+			}
+		}
+		return HealthCheckResult{
+			Status:  HealthStatusUnhealthy,
+			Payload: map[string]any{"expectedVersion": expected, "err": err.Error()},
+		}
+	}
+
+	payload := map[string]any{"expectedVersion": expected, "version": version, "dirty": dirty}
+	switch {
+	case dirty:
+		payload["err"] = "the last migration failed and the schema is dirty"
+		return HealthCheckResult{Status: HealthStatusUnhealthy, Payload: payload}
+	case version < int64(expected):
+		payload["err"] = "the schema is older than the binary expects, apply migrations"
+		return HealthCheckResult{Status: HealthStatusUnhealthy, Payload: payload}
+	case version > int64(expected):
+		// This is expected mid-deploy, when a newer binary has migrated
+		// the schema while this one is still running.
+		payload["err"] = "the schema is newer than the binary expects"
+		return HealthCheckResult{Status: HealthStatusDegraded, Payload: payload}
+	}
+	return HealthCheckResult{Status: HealthStatusHealthy, Payload: payload}
+}
+
+// poolSaturationCheck compares the pool's stats to those of its previous
+// evaluation, so that it reports recent saturation rather than the average
+// over the pool's whole lifetime.
+type poolSaturationCheck struct {
+	dbPool    *pgxpool.Pool
+	threshold time.Duration
+
+	mu                    sync.Mutex
+	prevAcquireCount      int64
+	prevEmptyAcquireCount int64
+	prevAcquireDuration   time.Duration
+}
+
+func (p *poolSaturationCheck) check(_ context.Context, _ *slog.Logger) HealthCheckResult {
+	stat := p.dbPool.Stat()
+
+	p.mu.Lock()
+	acquires := stat.AcquireCount() - p.prevAcquireCount
+	emptyAcquires := stat.EmptyAcquireCount() - p.prevEmptyAcquireCount
+	acquireDuration := stat.AcquireDuration() - p.prevAcquireDuration
+	p.prevAcquireCount = stat.AcquireCount()
+	p.prevEmptyAcquireCount = stat.EmptyAcquireCount()
+	p.prevAcquireDuration = stat.AcquireDuration()
+	p.mu.Unlock()
+
+	var avgAcquireWait time.Duration
+	if acquires > 0 {
+		avgAcquireWait = acquireDuration / time.Duration(acquires)
+	}
+	payload := map[string]any{
+		"acquiredConns":  stat.AcquiredConns(),
+		"idleConns":      stat.IdleConns(),
+		"maxConns":       stat.MaxConns(),
+		"acquires":       acquires,
+		"emptyAcquires":  emptyAcquires,
+		"avgAcquireWait": avgAcquireWait.String(),
+	}
+	if p.threshold > 0 && avgAcquireWait > p.threshold {
+		payload["err"] = fmt.Sprintf("acquiring a connection took %s on average, more than %s", avgAcquireWait, p.threshold)
+		return HealthCheckResult{Status: HealthStatusDegraded, Payload: payload}
+	}
+	return HealthCheckResult{Status: HealthStatusHealthy, Payload: payload}
+}
@@ -0,0 +1,162 @@
+// Package dberr classifies the errors returned by pgx into sentinel errors so
+// that callers don't need to know pgx's error types or Postgres' SQLSTATE
+// codes.
+//
+//	err = dberr.Classify(err)
+//	if errors.Is(err, dberr.ErrUniqueViolation) && dberr.Constraint(err) == "eras_name_key" {
+//		...
+//	}
+//
+// Classified errors still wrap the original error, so errors.As can retrieve
+// the *pgconn.PgError and errors.Is still matches context errors.
+package dberr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNoRows is returned when a query expecting a row returned none.
+	ErrNoRows = errors.New("no rows in result set")
+
+	ErrUniqueViolation     = errors.New("unique constraint violated")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violated")
+	ErrCheckViolation      = errors.New("check constraint violated")
+	ErrExclusionViolation  = errors.New("exclusion constraint violated")
+	ErrNotNullViolation    = errors.New("not null constraint violated")
+
+	// ErrSerializationFailure is returned when a transaction conflicted with
+	// a concurrent transaction; it may succeed when retried.
+	ErrSerializationFailure = errors.New("transaction could not be serialized")
+	// ErrDeadlock is returned when a transaction was aborted to break a
+	// deadlock; it may succeed when retried.
+	ErrDeadlock = errors.New("transaction deadlocked")
+
+	// ErrConnection is returned when the connection to the database could not
+	// be established or was lost.
+	ErrConnection = errors.New("database connection failed")
+	// ErrQueryCanceled is returned when a query was canceled, either by its
+	// context or by the database (such as by statement_timeout).
+	ErrQueryCanceled = errors.New("query was canceled")
+)
+
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+
+var sqlStateKinds = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23514": ErrCheckViolation,
+	"23P01": ErrExclusionViolation,
+	"23502": ErrNotNullViolation,
+	"40001": ErrSerializationFailure,
+	"40P01": ErrDeadlock,
+	"57014": ErrQueryCanceled,
+	"57P01": ErrConnection, // admin_shutdown
+	"57P02": ErrConnection, // crash_shutdown
+	"57P03": ErrConnection, // cannot_connect_now
+}
+
+// Error is a classified database error.
+type Error struct {
+	// Kind is one of this package's sentinel errors.
+	Kind error
+	// SQLState is empty if the error didn't come from Postgres.
+	SQLState string
+	// Constraint, Table, and Column are set when Postgres reported them.
+	Constraint string
+	Table      string
+	Column     string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns err wrapped in an *Error if it's recognized, else err
+// unchanged. A nil or already classified err is returned as is.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		kind, ok := sqlStateKinds[pgErr.Code]
+		if !ok && strings.HasPrefix(pgErr.Code, "08") {
+			kind, ok = ErrConnection, true
+		}
+		if !ok {
+			return err
+		}
+		return &Error{
+			Kind:       kind,
+			SQLState:   pgErr.Code,
+			Constraint: pgErr.ConstraintName,
+			Table:      pgErr.TableName,
+			Column:     pgErr.ColumnName,
+			Err:        err,
+		}
+	}
+
+	var kind error
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		kind = ErrNoRows
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		kind = ErrQueryCanceled
+	case isConnectionError(err):
+		kind = ErrConnection
+	default:
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func isConnectionError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Constraint returns the name of the constraint that err violated, or "" if
+// none was reported.
+func Constraint(err error) string {
+	var classified *Error
+	if errors.As(Classify(err), &classified) {
+		return classified.Constraint
+	}
+	return ""
+}
+
+// IsRetryable reports if err is a serialization failure or deadlock, which
+// may succeed if the transaction is retried.
+func IsRetryable(err error) bool {
+	err = Classify(err)
+	return errors.Is(err, ErrSerializationFailure) || errors.Is(err, ErrDeadlock)
+}
@@ -0,0 +1,98 @@
+package dberr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestClassify(t *testing.T) {
+	pgErr := func(code string) *pgconn.PgError {
+		return &pgconn.PgError{Code: code, Message: "from Postgres"}
+	}
+	uniqueErr := &pgconn.PgError{Code: "23505", ConstraintName: "eras_name_key", TableName: "eras"}
+	for _, tc := range []struct {
+		name string
+		err  error
+		// wantKind is nil if err isn't recognized, so is returned unchanged.
+		wantKind       error
+		wantSQLState   string
+		wantConstraint string
+		wantRetryable  bool
+	}{
+		{name: "unique violation", err: uniqueErr, wantKind: ErrUniqueViolation, wantSQLState: "23505", wantConstraint: "eras_name_key"},
+		{name: "wrapped unique violation", err: fmt.Errorf("insert: %w", uniqueErr), wantKind: ErrUniqueViolation, wantSQLState: "23505", wantConstraint: "eras_name_key"},
+		{name: "foreign key violation", err: pgErr("23503"), wantKind: ErrForeignKeyViolation, wantSQLState: "23503"},
+		{name: "check violation", err: pgErr("23514"), wantKind: ErrCheckViolation, wantSQLState: "23514"},
+		{name: "exclusion violation", err: pgErr("23P01"), wantKind: ErrExclusionViolation, wantSQLState: "23P01"},
+		{name: "not null violation", err: pgErr("23502"), wantKind: ErrNotNullViolation, wantSQLState: "23502"},
+		{name: "serialization failure", err: pgErr("40001"), wantKind: ErrSerializationFailure, wantSQLState: "40001", wantRetryable: true},
+		{name: "wrapped deadlock", err: fmt.Errorf("commit: %w", pgErr("40P01")), wantKind: ErrDeadlock, wantSQLState: "40P01", wantRetryable: true},
+		{name: "query canceled", err: pgErr("57014"), wantKind: ErrQueryCanceled, wantSQLState: "57014"},
+		{name: "admin shutdown", err: pgErr("57P01"), wantKind: ErrConnection, wantSQLState: "57P01"},
+		{name: "crash shutdown", err: pgErr("57P02"), wantKind: ErrConnection, wantSQLState: "57P02"},
+		{name: "cannot connect now", err: pgErr("57P03"), wantKind: ErrConnection, wantSQLState: "57P03"},
+		{name: "connection exception class", err: pgErr("08006"), wantKind: ErrConnection, wantSQLState: "08006"},
+		{name: "unrecognized SQLSTATE", err: pgErr("42P01")},
+		{name: "no rows", err: pgx.ErrNoRows, wantKind: ErrNoRows},
+		{name: "wrapped no rows", err: fmt.Errorf("get current era: %w", pgx.ErrNoRows), wantKind: ErrNoRows},
+		{name: "context canceled", err: context.Canceled, wantKind: ErrQueryCanceled},
+		{name: "wrapped context deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), wantKind: ErrQueryCanceled},
+		{name: "network error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, wantKind: ErrConnection},
+		{name: "unexpected EOF", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), wantKind: ErrConnection},
+		{name: "unrelated", err: errors.New("something else")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Classify(tc.err)
+			if tc.wantKind == nil {
+				if got != tc.err {
+					t.Fatalf("Expected the error to be returned unchanged, got %v", got)
+				}
+			} else {
+				var classified *Error
+				if !errors.As(got, &classified) || !errors.Is(got, tc.wantKind) {
+					t.Fatalf("Expected %v, got %v", tc.wantKind, got)
+				}
+				if classified.SQLState != tc.wantSQLState {
+					t.Fatalf("Expected SQLSTATE %q, got %q", tc.wantSQLState, classified.SQLState)
+				}
+			}
+			if !errors.Is(got, tc.err) {
+				t.Fatalf("Expected the classified error to still wrap %v", tc.err)
+			}
+			if constraint := Constraint(tc.err); constraint != tc.wantConstraint {
+				t.Fatalf("Expected constraint %q, got %q", tc.wantConstraint, constraint)
+			}
+			if retryable := IsRetryable(tc.err); retryable != tc.wantRetryable {
+				t.Fatalf("Expected IsRetryable to be %t, got %t", tc.wantRetryable, retryable)
+			}
+		})
+	}
+}
+
+func TestClassifyKeepsTheOriginalError(t *testing.T) {
+	if Classify(nil) != nil {
+		t.Fatal("Expected nil to stay nil")
+	}
+
+	original := &pgconn.PgError{Code: "23505", ConstraintName: "eras_name_key"}
+	classified := Classify(fmt.Errorf("insert: %w", original))
+	var pgErr *pgconn.PgError
+	if !errors.As(classified, &pgErr) || pgErr != original {
+		t.Fatalf("Expected the *pgconn.PgError to be retrievable, got %v", classified)
+	}
+	if again := Classify(classified); again != classified {
+		t.Fatalf("Expected an already classified error to be returned as is, got %v", again)
+	}
+
+	canceled := Classify(context.Canceled)
+	if !errors.Is(canceled, ErrQueryCanceled) || !errors.Is(canceled, context.Canceled) {
+		t.Fatalf("Expected context errors to still match, got %v", canceled)
+	}
+}
@@ -3,3 +3,7 @@ package common
 import "errors"
 
 var ErrStaleDBInput = errors.New("stale input data was given to a SQL query")
+
+// ErrTxConflict is returned when a transaction still conflicted with
+// concurrent transactions after being retried.
+var ErrTxConflict = errors.New("transaction conflicted with concurrent transactions")
@@ -0,0 +1,38 @@
+// Package features holds the feature flags enabled by config. The flags can
+// be replaced while the app is running, so callers should check Enabled each
+// time rather than caching the result.
+package features
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+var enabled atomic.Pointer[[]string]
+
+// Set replaces the enabled flags with those named in the comma-separated
+// list. Whitespace and empty names are ignored.
+func Set(list string) {
+	names := Parse(list)
+	enabled.Store(&names)
+}
+
+// Enabled reports whether the named flag is enabled.
+func Enabled(name string) bool {
+	names := enabled.Load()
+	return names != nil && slices.Contains(*names, name)
+}
+
+// Parse splits a comma-separated list of flag names, sorted and without
+// duplicates.
+func Parse(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
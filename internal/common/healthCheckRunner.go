@@ -0,0 +1,184 @@
+package common
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+)
+
+// HealthCheckRunner evaluates health checks in the background on an interval
+// so that health endpoints serve cached results instead of hitting
+// dependencies on every request.
+//
+// A history of recent results and status transitions is kept per check, and
+// transitions are logged once when they occur.
+type HealthCheckRunner struct {
+	healthChecks []HealthCheck
+	interval     time.Duration
+	clock        clock.Clock
+	slogger      *slog.Logger
+
+	mu          sync.RWMutex
+	evaluated   bool
+	evaluatedAt time.Time
+	duration    time.Duration
+	latest      []healthCheckCheck
+	histories   []*ringBuffer[healthCheckSample]
+	transitions []*ringBuffer[healthStatusChanged]
+}
+
+type healthCheckSample struct {
+	Status      string    `json:"status" enum:"Healthy,Degraded,Unhealthy"`
+	Duration    string    `json:"duration" example:"14.916111ms"`
+	TimedOut    bool      `json:"timedOut"`
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+}
+
+type healthStatusChanged struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// NewHealthCheckRunner will keep up to historySize results and transitions
+// per check. Evaluation times and ages are read from clk.
+func NewHealthCheckRunner(
+	healthChecks []HealthCheck,
+	interval time.Duration,
+	historySize int,
+	clk clock.Clock,
+	slogger *slog.Logger,
+) *HealthCheckRunner {
+	r := &HealthCheckRunner{
+		healthChecks: healthChecks,
+		interval:     interval,
+		clock:        clk,
+		slogger:      slogger,
+		latest:       make([]healthCheckCheck, len(healthChecks)),
+		histories:    make([]*ringBuffer[healthCheckSample], len(healthChecks)),
+		transitions:  make([]*ringBuffer[healthStatusChanged], len(healthChecks)),
+	}
+	for i := range healthChecks {
+		r.histories[i] = newRingBuffer[healthCheckSample](historySize)
+		r.transitions[i] = newRingBuffer[healthStatusChanged](historySize)
+	}
+	return r
+}
+
+// Run evaluates the health checks immediately and then every interval until
+// ctx is done.
+func (r *HealthCheckRunner) Run(ctx context.Context) {
+	r.Evaluate(ctx)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate runs every health check concurrently once and records the results.
+func (r *HealthCheckRunner) Evaluate(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "healthChecks")
+	defer span.End()
+
+	results := make([]healthCheckCheck, len(r.healthChecks))
+	evaluatedAt := r.clock.Now()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, healthCheck := range r.healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, r.slogger, r.clock, healthCheck)
+		}()
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, result := range results {
+		prevStatus := "Unevaluated"
+		if r.evaluated {
+			prevStatus = r.latest[i].Status
+		}
+		if result.Status != prevStatus {
+			r.transitions[i].push(healthStatusChanged{
+				From: prevStatus,
+				To:   result.Status,
+				At:   result.EvaluatedAt,
+			})
+			level := slog.LevelError
+			if result.Status == string(HealthStatusHealthy) {
+				level = slog.LevelInfo
+			}
+			r.slogger.Log(ctx, level, "A health check changed status",
+				slog.String("name", result.Name),
+				slog.String("from", prevStatus),
+				slog.String("to", result.Status),
+				slog.Bool("timedOut", result.TimedOut),
+				slog.Any("payload", result.Payload))
+		}
+		r.histories[i].push(healthCheckSample{
+			Status:      result.Status,
+			Duration:    result.Duration,
+			TimedOut:    result.TimedOut,
+			EvaluatedAt: result.EvaluatedAt,
+		})
+		r.latest[i] = result
+	}
+	r.evaluated = true
+	r.evaluatedAt = evaluatedAt
+	r.duration = duration
+}
+
+// overview returns the latest results of the checks participating in probe,
+// or every check if probe is zero. Before the first evaluation completes, the
+// overview is unhealthy, except for liveness: a slow first evaluation means
+// the process is still starting, not that it needs to be restarted.
+func (r *HealthCheckRunner) overview(probe Probe, includeHistory bool) healthCheckOverview {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.evaluated {
+		status := HealthStatusUnhealthy
+		if probe == ProbeLiveness {
+			status = HealthStatusHealthy
+		}
+		return healthCheckOverview{
+			Status:   string(status),
+			Duration: time.Duration(0).String(),
+			Age:      time.Duration(0).String(),
+			Checks:   []healthCheckCheck{},
+		}
+	}
+
+	now := r.clock.Now()
+	overview := healthCheckOverview{
+		Duration:    r.duration.String(),
+		EvaluatedAt: r.evaluatedAt,
+		Age:         now.Sub(r.evaluatedAt).String(),
+		Checks:      make([]healthCheckCheck, 0, len(r.latest)),
+	}
+	for i, check := range r.latest {
+		if probe != 0 && r.healthChecks[i].Probes&probe == 0 {
+			continue
+		}
+		check.Age = now.Sub(check.EvaluatedAt).String()
+		if includeHistory {
+			check.History = r.histories[i].slice()
+			check.Transitions = r.transitions[i].slice()
+		}
+		overview.Checks = append(overview.Checks, check)
+	}
+	overview.Status = string(aggregateHealthStatus(overview.Checks))
+	return overview
+}
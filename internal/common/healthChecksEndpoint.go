@@ -1,14 +1,32 @@
 package common
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("github.com/sawyerwatts/world-one/internal/common")
+
+var healthCheckStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "w1",
+	Name:      "health_check_status",
+	Help:      "1 for the status the named health check last reported, else 0.",
+}, []string{"name", "status"})
+
 type HealthStatus string
 
 // HealthStatusHealthy indicates that the app or health check are running as
@@ -23,10 +41,39 @@ const HealthStatusDegraded HealthStatus = "Degraded"
 // capacity.
 const HealthStatusUnhealthy HealthStatus = "Unhealthy"
 
+// Probe is a bitmask of the probe endpoints that a HealthCheck participates
+// in, which lets an orchestrator act on a relevant subset of checks.
+type Probe uint8
+
+const (
+	// ProbeLiveness checks should only fail when the process needs to be
+	// restarted.
+	ProbeLiveness Probe = 1 << iota
+	// ProbeReadiness checks should fail when the process cannot currently
+	// serve traffic.
+	ProbeReadiness
+	// ProbeStartup checks should fail until the process has finished
+	// starting up.
+	ProbeStartup
+)
+
 type HealthCheck struct {
 	Name string
-	// Check is permitted to panic, that will be caught by the caller.
-	Check func(c *gin.Context, slogger *slog.Logger) HealthCheckResult
+	// Probes are the probe endpoints this check participates in. Every check
+	// is included in the overall health checks endpoint regardless.
+	Probes Probe
+	// Timeout is optional. When exceeded, the check's context is canceled and
+	// the check is reported as unhealthy and timed out.
+	Timeout time.Duration
+	// Critical checks make the overview unhealthy when they are unhealthy,
+	// rather than merely degraded.
+	Critical bool
+	// DegradedThreshold is optional. A healthy check that takes longer than
+	// this is reported as degraded.
+	DegradedThreshold time.Duration
+	// Check is permitted to panic, that will be caught by the caller. Checks
+	// are run concurrently, so they must not share unsynchronized state.
+	Check func(ctx context.Context, slogger *slog.Logger) HealthCheckResult
 }
 
 type HealthCheckResult struct {
@@ -34,77 +81,220 @@ type HealthCheckResult struct {
 	Payload map[string]any
 }
 
-func NewHealthChecksEndpoint(healthChecks []HealthCheck) func(c *gin.Context) {
-	type check struct {
-		Name     string         `json:"name"`
-		Status   string         `json:"status"`
-		Duration string         `json:"duration"`
-		Payload  map[string]any `json:"payloadDict"`
+type healthCheckCheck struct {
+	Name        string                `json:"name" example:"DB Connectivity"`
+	Status      string                `json:"status" enum:"Healthy,Degraded,Unhealthy"`
+	Duration    string                `json:"duration" example:"14.916111ms"`
+	Critical    bool                  `json:"critical" doc:"If true, this check being unhealthy makes the overview unhealthy."`
+	TimedOut    bool                  `json:"timedOut" doc:"If true, the check was unhealthy because it exceeded its timeout rather than failing."`
+	EvaluatedAt time.Time             `json:"evaluatedAt"`
+	Age         string                `json:"age" example:"3.2s"`
+	Payload     map[string]any        `json:"payloadDict"`
+	History     []healthCheckSample   `json:"history,omitempty"`
+	Transitions []healthStatusChanged `json:"transitions,omitempty"`
+}
+
+type healthCheckOverview struct {
+	Status       string             `json:"status" enum:"Healthy,Degraded,Unhealthy"`
+	Duration     string             `json:"duration" example:"14.916111ms"`
+	EvaluatedAt  time.Time          `json:"evaluatedAt"`
+	Age          string             `json:"age" example:"3.2s"`
+	ShuttingDown bool               `json:"shuttingDown,omitempty" doc:"Only present (as true) when readiness fails due to graceful shutdown."`
+	Checks       []healthCheckCheck `json:"checks"`
+}
+
+// RouteHealthChecks registers /healthChecks and the /health/* probes.
+func RouteHealthChecks(group *openapi.Group, runner *HealthCheckRunner, shutdownState *ShutdownState) {
+	operation := func(id string, summary string, description string) openapi.Operation {
+		return openapi.Operation{
+			ID:          id,
+			Summary:     summary,
+			Description: description,
+			Tags:        []string{"Operations"},
+			Params: []openapi.Param{{
+				Name:        "history",
+				In:          "query",
+				Description: "If true, include each check's recent results and status transitions.",
+				Type:        false,
+			}},
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Description: "OK, the checks are healthy or degraded", Body: healthCheckOverview{}},
+				{Status: http.StatusServiceUnavailable, Description: "Service Unavailable, the checks are unhealthy", Body: healthCheckOverview{}},
+			},
+		}
 	}
-	type healthCheckOverview struct {
-		Status   string  `json:"status"`
-		Duration string  `json:"duration"`
-		Checks   []check `json:"checks"`
+
+	group.GET("/healthChecks", operation("healthChecks", "Get health checks",
+		"Get the latest results of every health check. Checks are evaluated in\n"+
+			"the background, so see evaluatedAt and age for how fresh they are.\n"),
+		NewHealthChecksEndpoint(runner))
+	group.GET("/health/live", operation("healthLive", "Liveness probe",
+		"Get the latest results of the health checks that indicate if the process needs to be restarted.\n"),
+		NewProbeEndpoint(runner, ProbeLiveness, shutdownState))
+	group.GET("/health/ready", operation("healthReady", "Readiness probe",
+		"Get the latest results of the health checks that indicate if the process can serve traffic.\n\n"+
+			"This fails regardless of the checks once graceful shutdown has begun.\n"),
+		NewProbeEndpoint(runner, ProbeReadiness, shutdownState))
+	group.GET("/health/startup", operation("healthStartup", "Startup probe",
+		"Get the latest results of the health checks that indicate if the process has finished starting.\n"),
+		NewProbeEndpoint(runner, ProbeStartup, shutdownState))
+}
+
+// NewHealthChecksEndpoint serves the runner's latest results for every
+// health check. HTTP 503 is returned if the overview is unhealthy, else 200.
+//
+// If the query parameter history is true, each check's recent results and
+// status transitions are included.
+func NewHealthChecksEndpoint(runner *HealthCheckRunner) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		overview := runner.overview(0, c.Query("history") == "true")
+		c.JSON(overviewHTTPStatus(overview), overview)
+	}
+}
+
+// NewProbeEndpoint serves the runner's latest results for the health checks
+// participating in probe. HTTP 503 is returned if the overview is unhealthy,
+// else 200.
+//
+// Once shutdownState has begun, readiness probes fail immediately so that
+// traffic is drained before the server stops.
+func NewProbeEndpoint(runner *HealthCheckRunner, probe Probe, shutdownState *ShutdownState) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if probe&ProbeReadiness != 0 && shutdownState.ShuttingDown() {
+			c.JSON(http.StatusServiceUnavailable, healthCheckOverview{
+				Status:       string(HealthStatusUnhealthy),
+				Duration:     time.Duration(0).String(),
+				Age:          time.Duration(0).String(),
+				ShuttingDown: true,
+				Checks:       []healthCheckCheck{},
+			})
+			return
+		}
+
+		overview := runner.overview(probe, c.Query("history") == "true")
+		c.JSON(overviewHTTPStatus(overview), overview)
 	}
+}
 
-	execAndRecover := func(
-		c *gin.Context,
-		slogger *slog.Logger,
-		check func(c *gin.Context, slogger *slog.Logger) HealthCheckResult,
-	) (result HealthCheckResult) {
+// WriteOverview writes the runner's latest results for the health checks
+// participating in probe (or every check if probe is zero) as indented JSON,
+// like the endpoints serve them, and returns their overall status. This is
+// for reporting the results outside of the web API, such as from the CLI.
+func (r *HealthCheckRunner) WriteOverview(w io.Writer, probe Probe, includeHistory bool) (HealthStatus, error) {
+	overview := r.overview(probe, includeHistory)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return HealthStatus(overview.Status), encoder.Encode(overview)
+}
+
+func overviewHTTPStatus(overview healthCheckOverview) int {
+	if overview.Status == string(HealthStatusUnhealthy) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// aggregateHealthStatus is unhealthy if any critical check or every check is
+// unhealthy, degraded if any check is not healthy, else healthy.
+func aggregateHealthStatus(checks []healthCheckCheck) HealthStatus {
+	if len(checks) == 0 {
+		return HealthStatusHealthy
+	}
+	status := HealthStatusHealthy
+	allUnhealthy := true
+	for _, check := range checks {
+		if check.Status != string(HealthStatusHealthy) {
+			status = HealthStatusDegraded
+		}
+		if check.Status != string(HealthStatusUnhealthy) {
+			allUnhealthy = false
+		} else if check.Critical {
+			return HealthStatusUnhealthy
+		}
+	}
+	if allUnhealthy {
+		return HealthStatusUnhealthy
+	}
+	return status
+}
+
+func runHealthCheck(ctx context.Context, slogger *slog.Logger, clk clock.Clock, healthCheck HealthCheck) healthCheckCheck {
+	ctx, span := tracer.Start(ctx, "healthCheck "+healthCheck.Name)
+	defer span.End()
+	parentCtx := ctx
+	if healthCheck.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, healthCheck.Timeout)
+		defer cancel()
+	}
+
+	// The check is run in its own goroutine so that a check ignoring its
+	// context still can't hold up the overview past the timeout.
+	resultChan := make(chan HealthCheckResult, 1)
+	evaluatedAt := clk.Now()
+	checkStart := time.Now()
+	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				result = HealthCheckResult{
+				resultChan <- HealthCheckResult{
 					Status:  HealthStatusUnhealthy,
 					Payload: map[string]any{"panic": r},
 				}
 			}
 		}()
-		return check(c, slogger)
-	}
+		resultChan <- healthCheck.Check(ctx, slogger)
+	}()
 
-	return func(c *gin.Context) {
-		slogger := middleware.MustGetSlogger(c)
-
-		overview := healthCheckOverview{
-			Checks: make([]check, 0, len(healthChecks)),
+	var result HealthCheckResult
+	timedOut := false
+	select {
+	case result = <-resultChan:
+	case <-ctx.Done():
+		// Only the check's own timeout counts as timing out; the evaluation's
+		// context ending, such as at shutdown, is a plain failure.
+		timedOut = healthCheck.Timeout > 0 &&
+			errors.Is(ctx.Err(), context.DeadlineExceeded) &&
+			parentCtx.Err() == nil
+		reason := "health check was canceled: "
+		if timedOut {
+			reason = "health check did not complete in time: "
 		}
-
-		overviewStart := time.Now()
-		for _, individualHealthCheck := range healthChecks {
-			check := check{}
-			checkStart := time.Now()
-			result := execAndRecover(c, slogger, individualHealthCheck.Check)
-			checkEnd := time.Now()
-
-			check.Name = individualHealthCheck.Name
-			check.Status = string(result.Status)
-			check.Duration = checkEnd.Sub(checkStart).String()
-			check.Payload = result.Payload
-			overview.Checks = append(overview.Checks, check)
+		result = HealthCheckResult{
+			Status:  HealthStatusUnhealthy,
+			Payload: map[string]any{"err": reason + ctx.Err().Error()},
 		}
-		overviewEnd := time.Now()
-		overview.Duration = overviewEnd.Sub(overviewStart).String()
+	}
+	duration := time.Since(checkStart)
 
-		overview.Status = string(HealthStatusHealthy)
-		if len(healthChecks) == 0 {
-			c.JSON(http.StatusOK, overview)
-			return
-		}
-		allUnhealthy := true
-		for _, check := range overview.Checks {
-			if check.Status != string(HealthStatusHealthy) {
-				overview.Status = string(HealthStatusDegraded)
-				slogger.ErrorContext(c, "A health check did not come back healthy", slog.String("name", check.Name), slog.Any("payload", check.Payload))
-			}
-			if check.Status != string(HealthStatusUnhealthy) {
-				allUnhealthy = false
-			}
+	if result.Status == HealthStatusHealthy && healthCheck.DegradedThreshold > 0 && duration > healthCheck.DegradedThreshold {
+		result.Status = HealthStatusDegraded
+		if result.Payload == nil {
+			result.Payload = make(map[string]any, 1)
 		}
-		if allUnhealthy {
-			overview.Status = string(HealthStatusUnhealthy)
+		result.Payload["degradedThreshold"] = healthCheck.DegradedThreshold.String()
+	}
+
+	span.SetAttributes(
+		attribute.String("w1.healthCheck.status", string(result.Status)),
+		attribute.Bool("w1.healthCheck.timedOut", timedOut))
+	if result.Status != HealthStatusHealthy {
+		span.SetStatus(codes.Error, string(result.Status))
+	}
+	for _, status := range []HealthStatus{HealthStatusHealthy, HealthStatusDegraded, HealthStatusUnhealthy} {
+		value := 0.0
+		if result.Status == status {
+			value = 1
 		}
+		healthCheckStatus.WithLabelValues(healthCheck.Name, string(status)).Set(value)
+	}
 
-		c.JSON(http.StatusOK, overview)
+	return healthCheckCheck{
+		Name:        healthCheck.Name,
+		Status:      string(result.Status),
+		Duration:    duration.String(),
+		Critical:    healthCheck.Critical,
+		TimedOut:    timedOut,
+		EvaluatedAt: evaluatedAt,
+		Payload:     result.Payload,
 	}
 }
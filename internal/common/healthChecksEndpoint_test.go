@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+)
+
+func TestRunHealthCheckDistinguishesTimeoutsFromFailures(t *testing.T) {
+	blocks := func(ctx context.Context, _ *slog.Logger) HealthCheckResult {
+		<-ctx.Done()
+		return HealthCheckResult{Status: HealthStatusHealthy}
+	}
+	fails := func(context.Context, *slog.Logger) HealthCheckResult {
+		return HealthCheckResult{Status: HealthStatusUnhealthy}
+	}
+	withTimeout := func(d time.Duration) func() (context.Context, context.CancelFunc) {
+		return func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), d)
+		}
+	}
+	for _, tc := range []struct {
+		name         string
+		timeout      time.Duration
+		check        func(ctx context.Context, slogger *slog.Logger) HealthCheckResult
+		ctx          func() (context.Context, context.CancelFunc)
+		wantTimedOut bool
+	}{
+		{
+			name:         "exceeds its timeout",
+			timeout:      time.Millisecond,
+			check:        blocks,
+			ctx:          func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			wantTimedOut: true,
+		},
+		{
+			name:    "fails",
+			timeout: time.Second,
+			check:   fails,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+		},
+		{
+			name:  "evaluation canceled",
+			check: blocks,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+		},
+		{
+			name:  "evaluation deadline without a timeout",
+			check: blocks,
+			ctx:   withTimeout(10 * time.Millisecond),
+		},
+		{
+			name:    "evaluation deadline before the timeout",
+			timeout: time.Minute,
+			check:   blocks,
+			ctx:     withTimeout(10 * time.Millisecond),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			result := runHealthCheck(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), clock.System{}, HealthCheck{
+				Name:    tc.name,
+				Timeout: tc.timeout,
+				Check:   tc.check,
+			})
+			if result.Status != string(HealthStatusUnhealthy) {
+				t.Fatalf("Expected the check to be unhealthy, got %s", result.Status)
+			}
+			if result.TimedOut != tc.wantTimedOut {
+				t.Fatalf("Expected timedOut to be %t, got %t with payload %v", tc.wantTimedOut, result.TimedOut, result.Payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UseAccessLog is middleware that logs every request once it has completed.
+//
+// This is intended to be the first middleware so that it can time and observe
+// the whole chain, including requests that UseTraceUUIDAndSlogger rejects. As
+// such, the request's slogger is used when present, falling back on slogger
+// otherwise.
+func UseAccessLog(slogger *slog.Logger) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		requestSlogger := slogger
+		if sloggerAny, ok := c.Get(sloggerContextKey); ok {
+			requestSlogger = sloggerAny.(*slog.Logger)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		bytes := c.Writer.Size()
+		if bytes < 0 {
+			bytes = 0
+		}
+		status := c.Writer.Status()
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		requestSlogger.LogAttrs(c, level, "Request completed",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("latencyMS", latency.Milliseconds()),
+			slog.String("latency", latency.String()),
+			slog.Int("bytes", bytes))
+	}
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+// replayedHeaders are the response headers saved and replayed; the rest, like
+// the trace UUID, belong to the request that produced them.
+var replayedHeaders = []string{"Content-Type", "Location", "Link"}
+
+// IdempotencyCache remembers the responses to mutations sent with an
+// Idempotency-Key so that retries are replayed instead of repeated. Keys are
+// forgotten after ttl, and once maxKeys are remembered, the keys closest to
+// expiring are forgotten early.
+//
+// The cache is in memory, so a key is only honored by the instance that first
+// saw it.
+type IdempotencyCache struct {
+	ttl     time.Duration
+	maxKeys int
+	clock   clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	// fingerprint identifies the request, so a key can't be reused for a
+	// different one.
+	fingerprint string
+	inFlight    bool
+	expiresAt   time.Time
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+func NewIdempotencyCache(ttl time.Duration, maxKeys int, clk clock.Clock) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:     ttl,
+		maxKeys: maxKeys,
+		clock:   clk,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// UseIdempotency is middleware that makes POST, PUT, PATCH, and DELETE
+// requests with an Idempotency-Key header safe to retry. The first request
+// with a key is handled as usual and its response is saved; later requests
+// with the key get the saved response, with the Idempotent-Replayed header
+// set, without being handled again.
+//
+// A key reused for a different request (method, path, query, or body) is a
+// 422, and one whose first request is still being handled is a 409. 5xx and
+// 409 responses aren't saved since they're worth retrying for real.
+//
+// This expects UseTraceUUIDAndSlogger to have already run.
+func UseIdempotency(cache *IdempotencyCache) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutation(c.Request.Method) {
+			c.Next()
+			return
+		}
+		slogger := MustGetSlogger(c)
+		if len(key) > maxIdempotencyKeyLen {
+			problem.Abort(c, http.StatusBadRequest, "The Idempotency-Key header is longer than 255 characters")
+			return
+		}
+
+		fingerprint, err := fingerprintRequest(c.Request)
+		if err != nil {
+			slogger.WarnContext(c, "Failed to read the request body to fingerprint it", slog.String("err", err.Error()))
+			problem.Abort(c, http.StatusBadRequest, "The request body could not be read")
+			return
+		}
+
+		entry, isNew := cache.begin(key, fingerprint)
+		switch {
+		case entry.fingerprint != fingerprint:
+			slogger.WarnContext(c, "An Idempotency-Key was reused for a different request", slog.String("idempotencyKey", key))
+			problem.Abort(c, http.StatusUnprocessableEntity, "The Idempotency-Key was already used for a different request")
+			return
+		case !isNew && entry.inFlight:
+			c.Header("Retry-After", "1")
+			problem.Abort(c, http.StatusConflict, "A request with this Idempotency-Key is still being handled, try again")
+			return
+		case !isNew:
+			slogger.InfoContext(c, "Replaying the saved response for an Idempotency-Key", slog.String("idempotencyKey", key))
+			for name, values := range entry.header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+			c.Abort()
+			return
+		}
+
+		// If a handler panics, the key is forgotten so that it can be retried.
+		saved := false
+		defer func() {
+			if !saved {
+				cache.forget(key)
+			}
+		}()
+
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		status := c.Writer.Status()
+		if status >= 500 || status == http.StatusConflict {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range replayedHeaders {
+			if values := c.Writer.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		cache.finish(key, status, header, recorder.body.Bytes())
+		saved = true
+	}
+}
+
+// begin returns the entry for key. If there isn't one (or it expired), an
+// in-flight entry for fingerprint is added and isNew is true.
+func (cache *IdempotencyCache) begin(key string, fingerprint string) (entry idempotencyEntry, isNew bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := cache.clock.Now()
+	if existing, ok := cache.entries[key]; ok && now.Before(existing.expiresAt) {
+		return *existing, false
+	}
+	cache.makeRoomLocked(now)
+	added := &idempotencyEntry{
+		fingerprint: fingerprint,
+		inFlight:    true,
+		expiresAt:   now.Add(cache.ttl),
+	}
+	cache.entries[key] = added
+	return *added, true
+}
+
+func (cache *IdempotencyCache) finish(key string, status int, header http.Header, body []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if entry, ok := cache.entries[key]; ok {
+		entry.inFlight = false
+		entry.status = status
+		entry.header = header
+		entry.body = bytes.Clone(body)
+	}
+}
+
+func (cache *IdempotencyCache) forget(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.entries, key)
+}
+
+// makeRoomLocked forgets expired keys, and then the keys closest to expiring,
+// until another fits.
+func (cache *IdempotencyCache) makeRoomLocked(now time.Time) {
+	if len(cache.entries) < cache.maxKeys {
+		return
+	}
+	for key, entry := range cache.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(cache.entries, key)
+		}
+	}
+	for len(cache.entries) >= cache.maxKeys {
+		var oldestKey string
+		var oldest *idempotencyEntry
+		for key, entry := range cache.entries {
+			if oldest == nil || entry.expiresAt.Before(oldest.expiresAt) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		delete(cache.entries, oldestKey)
+	}
+}
+
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// fingerprintRequest hashes what identifies the request, restoring its body
+// for the handlers.
+func fingerprintRequest(req *http.Request) (string, error) {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.Path+"?"+req.URL.RawQuery+"\n")
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordingWriter keeps a copy of the body written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
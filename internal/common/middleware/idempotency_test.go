@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+)
+
+// idempotencyTestEnv serves POST /things, which responds with the status in
+// its status query parameter, and counts how many times it was handled.
+type idempotencyTestEnv struct {
+	cache   *IdempotencyCache
+	clock   *clock.Fake
+	router  *gin.Engine
+	handled atomic.Int64
+	// entered and release, if release is set, let a test hold a request in
+	// its handler.
+	entered chan struct{}
+	release chan struct{}
+	// panics makes the next request panic in its handler.
+	panics atomic.Bool
+}
+
+func newIdempotencyTestEnv(t *testing.T, ttl time.Duration, maxKeys int) *idempotencyTestEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env := &idempotencyTestEnv{clock: clock.NewFake(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))}
+	env.cache = NewIdempotencyCache(ttl, maxKeys, env.clock)
+	env.router = gin.New()
+	env.router.Use(
+		UseRecovery(slogger),
+		UseTraceUUIDAndSlogger(context.Background(), slogger),
+		UseIdempotency(env.cache))
+	env.router.POST("/things", func(c *gin.Context) {
+		handled := env.handled.Add(1)
+		if env.panics.CompareAndSwap(true, false) {
+			panic("handler failed")
+		}
+		if env.release != nil {
+			env.entered <- struct{}{}
+			<-env.release
+		}
+		status := http.StatusCreated
+		if s := c.Query("status"); s != "" {
+			status, _ = strconv.Atoi(s)
+		}
+		c.Header("Location", "/things/"+strconv.FormatInt(handled, 10))
+		c.String(status, "handled %d", handled)
+	})
+	return env
+}
+
+func (env *idempotencyTestEnv) post(target string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysTheSavedResponse(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Minute, 10)
+
+	first := env.post("/things", "key", "body")
+	second := env.post("/things", "key", "body")
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("Expected both to be 201, got %d and %d", first.Code, second.Code)
+	}
+	if env.handled.Load() != 1 {
+		t.Fatalf("Expected the request to be handled once, got %d", env.handled.Load())
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+		t.Fatalf("Expected the first response to be replayed, got %q", second.Body.String())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatal("Expected only the replayed response to be marked as replayed")
+	}
+	if first.Header().Get(TraceUUIDHeader) == second.Header().Get(TraceUUIDHeader) {
+		t.Fatal("Expected the replay to have its own trace UUID")
+	}
+
+	// Requests without a key are handled every time.
+	env.post("/things", "", "body")
+	env.post("/things", "", "body")
+	if env.handled.Load() != 3 {
+		t.Fatalf("Expected requests without a key to be handled, got %d handled", env.handled.Load())
+	}
+}
+
+func TestIdempotencyRejectsAKeyReusedForADifferentRequest(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Minute, 10)
+	if w := env.post("/things", "key", "body"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	for _, tc := range []struct{ target, body string }{
+		{target: "/things", body: "another body"},
+		{target: "/things?status=201", body: "body"},
+	} {
+		if w := env.post(tc.target, "key", tc.body); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected 422 for %s with %q, got %d: %s", tc.target, tc.body, w.Code, w.Body.String())
+		}
+	}
+	if env.handled.Load() != 1 {
+		t.Fatalf("Expected the rejected requests not to be handled, got %d handled", env.handled.Load())
+	}
+}
+
+func TestIdempotencyRejectsAKeyStillInFlight(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Minute, 10)
+	env.entered = make(chan struct{})
+	env.release = make(chan struct{})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- env.post("/things", "key", "body") }()
+	<-env.entered
+
+	w := env.post("/things", "key", "body")
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected a 409 with Retry-After, got %d with %v", w.Code, w.Header())
+	}
+
+	close(env.release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Fatalf("Expected the first request to complete, got %d", first.Code)
+	}
+	if w := env.post("/things", "key", "body"); w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("Expected the completed request to be replayed, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyDoesNotSaveRetryableResponses(t *testing.T) {
+	for _, status := range []int{http.StatusConflict, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			env := newIdempotencyTestEnv(t, time.Minute, 10)
+			target := "/things?status=" + strconv.Itoa(status)
+			for range 2 {
+				if w := env.post(target, "key", "body"); w.Code != status || w.Header().Get(IdempotentReplayedHeader) != "" {
+					t.Fatalf("Expected an unreplayed %d, got %d", status, w.Code)
+				}
+			}
+			if env.handled.Load() != 2 {
+				t.Fatalf("Expected both requests to be handled, got %d handled", env.handled.Load())
+			}
+		})
+	}
+}
+
+func TestIdempotencyForgetsAKeyAfterAPanic(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Minute, 10)
+	env.panics.Store(true)
+	if w := env.post("/things", "key", "body"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected the panic to be a 500, got %d", w.Code)
+	}
+	if w := env.post("/things", "key", "body"); w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("Expected the retry to be handled, got %d", w.Code)
+	}
+	if env.handled.Load() != 2 {
+		t.Fatalf("Expected the retry to be handled, got %d handled", env.handled.Load())
+	}
+}
+
+func TestIdempotencyForgetsExpiredKeys(t *testing.T) {
+	env := newIdempotencyTestEnv(t, time.Minute, 10)
+	env.post("/things", "key", "body")
+	env.clock.Advance(time.Minute)
+
+	// Once expired, the key can even be used for a different request.
+	if w := env.post("/things", "key", "another body"); w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("Expected the expired key to be handled anew, got %d", w.Code)
+	}
+	if env.handled.Load() != 2 {
+		t.Fatalf("Expected both requests to be handled, got %d handled", env.handled.Load())
+	}
+}
+
+func TestIdempotencyCacheMakesRoom(t *testing.T) {
+	keys := func(cache *IdempotencyCache) []string {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		var keys []string
+		for key := range cache.entries {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	t.Run("forgets the keys closest to expiring", func(t *testing.T) {
+		env := newIdempotencyTestEnv(t, time.Minute, 2)
+		env.post("/things", "a", "body")
+		env.clock.Advance(time.Second)
+		env.post("/things", "b", "body")
+		env.clock.Advance(time.Second)
+		env.post("/things", "c", "body")
+		if got := keys(env.cache); !slices.Equal(got, []string{"b", "c"}) {
+			t.Fatalf("Expected a to be forgotten, got %v", got)
+		}
+	})
+
+	t.Run("forgets every expired key", func(t *testing.T) {
+		env := newIdempotencyTestEnv(t, time.Minute, 3)
+		env.post("/things", "a", "body")
+		env.post("/things", "b", "body")
+		env.clock.Advance(50 * time.Second)
+		env.post("/things", "c", "body")
+		env.clock.Advance(20 * time.Second)
+		env.post("/things", "d", "body")
+		if got := keys(env.cache); !slices.Equal(got, []string{"c", "d"}) {
+			t.Fatalf("Expected a and b to be forgotten, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+// UseRecovery is middleware that recovers from panics further down the chain,
+// logs the panic and its stack, and responds with a 500 problem.
+//
+// Like UseAccessLog, the request's slogger is used when present, falling back
+// on slogger otherwise.
+func UseRecovery(slogger *slog.Logger) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if err, ok := r.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(r)
+			}
+
+			requestSlogger := slogger
+			if sloggerAny, ok := c.Get(sloggerContextKey); ok {
+				requestSlogger = sloggerAny.(*slog.Logger)
+			}
+
+			if isBrokenConnection(r) {
+				requestSlogger.WarnContext(c, "Recovered from a panic caused by a broken connection", slog.Any("panic", r))
+				_ = c.Error(r.(error))
+				c.Abort()
+				return
+			}
+
+			requestSlogger.ErrorContext(c, "Recovered from a panic",
+				slog.Any("panic", r),
+				slog.Any("stack", stackFrames(3)))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			problem.Abort(c, http.StatusInternalServerError, "An unexpected error occurred")
+		}()
+		c.Next()
+	}
+}
+
+type stackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// stackFrames returns the current goroutine's stack, skipping the given number
+// of frames (runtime.Callers itself is always skipped).
+func stackFrames(skip int) []stackFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	stack := make([]stackFrame, 0, n)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, stackFrame{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		})
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// isBrokenConnection reports if the panic is due to the client going away, in
+// which case there's nobody to respond to.
+func isBrokenConnection(r any) bool {
+	err, ok := r.(error)
+	if !ok {
+		return false
+	}
+	var netErr *net.OpError
+	if !errors.As(err, &netErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if errors.As(netErr, &syscallErr) {
+		if errors.Is(syscallErr.Err, syscall.EPIPE) || errors.Is(syscallErr.Err, syscall.ECONNRESET) {
+			return true
+		}
+		msg := strings.ToLower(syscallErr.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
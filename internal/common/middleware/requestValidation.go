@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+// RequestValidator is middleware that validates requests' path, query, and
+// header parameters and JSON bodies against the operations in an OpenAPI
+// document. Invalid requests are aborted with a 400 problem listing every
+// violation, so handlers can trust their inputs.
+//
+// Since the document is built as routes are registered, which is after the
+// middleware must be added, the document is given to Load afterwards. Until
+// then, and for routes not in the document (like the docs), requests are
+// passed through.
+//
+// This expects UseTraceUUIDAndSlogger to have already run.
+type RequestValidator struct {
+	specRouter atomic.Pointer[routers.Router]
+}
+
+// Load validates requests against spec from now on. spec must already be
+// validated.
+func (v *RequestValidator) Load(spec *openapi3.T) error {
+	// The spec's servers are where the API is deployed; requests are matched
+	// on path alone so that they validate no matter the host they arrive on.
+	anyServer := *spec
+	anyServer.Servers = nil
+	specRouter, err := gorillamux.NewRouter(&anyServer)
+	if err != nil {
+		return fmt.Errorf("failed to route the OpenAPI spec: %w", err)
+	}
+	v.specRouter.Store(&specRouter)
+	return nil
+}
+
+var requestValidationOptions = &openapi3filter.Options{
+	MultiError:         true,
+	AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+}
+
+func (v *RequestValidator) Handle(c *gin.Context) {
+	specRouter := v.specRouter.Load()
+	if specRouter == nil {
+		c.Next()
+		return
+	}
+	route, pathParams, err := (*specRouter).FindRoute(c.Request)
+	if err != nil {
+		c.Next()
+		return
+	}
+
+	err = openapi3filter.ValidateRequest(c, &openapi3filter.RequestValidationInput{
+		Request:    c.Request,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    requestValidationOptions,
+	})
+	if err == nil {
+		c.Next()
+		return
+	}
+
+	violations := requestViolations(err)
+	MustGetSlogger(c).WarnContext(c, "The request does not match the OpenAPI spec",
+		slog.String("operation", route.Method+" "+route.Path),
+		slog.Any("violations", violations))
+	problem.AbortWithViolations(c, http.StatusBadRequest, "The request is invalid, see violations", violations)
+}
+
+// requestViolations flattens the errors returned by
+// openapi3filter.ValidateRequest.
+func requestViolations(err error) []problem.Violation {
+	// A RequestError may wrap a MultiError of its schema errors, so only
+	// MultiErrors that aren't wrapped list separate requests' errors.
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var violations []problem.Violation
+		for _, e := range multi {
+			violations = append(violations, requestViolations(e)...)
+		}
+		return violations
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return []problem.Violation{{In: "request", Reason: err.Error()}}
+	}
+
+	if reqErr.Parameter != nil {
+		return []problem.Violation{{
+			In:     reqErr.Parameter.In,
+			Name:   reqErr.Parameter.Name,
+			Reason: violationReason(reqErr),
+		}}
+	}
+
+	var schemaErrs openapi3.MultiError
+	if errors.As(reqErr.Err, &schemaErrs) {
+		var violations []problem.Violation
+		for _, e := range schemaErrs {
+			violations = append(violations, bodyViolation(e))
+		}
+		return violations
+	}
+	if reqErr.Err != nil {
+		return []problem.Violation{bodyViolation(reqErr.Err)}
+	}
+	return []problem.Violation{{In: "body", Reason: reqErr.Reason}}
+}
+
+func bodyViolation(err error) problem.Violation {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return problem.Violation{
+			In:     "body",
+			Name:   "/" + strings.Join(schemaErr.JSONPointer(), "/"),
+			Reason: schemaErr.Reason,
+		}
+	}
+	return problem.Violation{In: "body", Reason: err.Error()}
+}
+
+// violationReason prefers the most specific reason a parameter is invalid.
+func violationReason(reqErr *openapi3filter.RequestError) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(reqErr.Err, &schemaErr) {
+		return schemaErr.Reason
+	}
+	var parseErr *openapi3filter.ParseError
+	if errors.As(reqErr.Err, &parseErr) {
+		return parseErr.Error()
+	}
+	if reqErr.Err != nil {
+		return reqErr.Err.Error()
+	}
+	return reqErr.Reason
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+// TimeoutHandler is http.TimeoutHandler except that timeouts are reported as
+// problem details containing the request's trace UUID.
+//
+// So that the trace UUID is known here, one is generated and added to the
+// request's headers when the caller did not supply one;
+// UseTraceUUIDAndSlogger will then adopt it.
+//
+// dt is called once per request so that the timeout can be changed while the
+// server is running.
+func TimeoutHandler(h http.Handler, dt func() time.Duration) http.Handler {
+	return &timeoutHandler{
+		handler: h,
+		dt:      dt,
+	}
+}
+
+type timeoutHandler struct {
+	handler http.Handler
+	dt      func() time.Duration
+}
+
+func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	traceUUID := r.Header.Get(TraceUUIDHeader)
+	if traceUUID == "" {
+		newUUID, err := uuid.NewV7()
+		if err != nil {
+			panic("Failed to create a new trace UUID: " + err.Error())
+		}
+		traceUUID = newUUID.String()
+		r.Header.Set(TraceUUIDHeader, traceUUID)
+	}
+
+	dt := h.dt()
+	ctx, cancel := context.WithTimeout(r.Context(), dt)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	done := make(chan struct{})
+	panicChan := make(chan any, 1)
+	tw := &timeoutWriter{header: make(http.Header)}
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		h.handler.ServeHTTP(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		dst := w.Header()
+		for k, vv := range tw.header {
+			dst[k] = vv
+		}
+		if !tw.wroteHeader {
+			tw.code = http.StatusOK
+		}
+		w.WriteHeader(tw.code)
+		_, _ = w.Write(tw.buf.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+		w.Header().Set(TraceUUIDHeader, traceUUID)
+		details := problem.New(
+			http.StatusServiceUnavailable,
+			fmt.Sprintf("The request timed out as it ran longer than %d milliseconds", dt.Milliseconds()),
+			traceUUID)
+		details.Type = problem.TypeTimeout
+		_ = problem.Write(w, details)
+	}
+}
+
+// timeoutWriter buffers the handler's response so that it can be discarded
+// if the handler runs out of time.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
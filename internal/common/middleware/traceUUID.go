@@ -7,6 +7,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const TraceUUIDContextKey = "traceUUID"
@@ -17,18 +20,24 @@ const TraceUUIDHeader = "X-TRACE-UUID"
 // response trace UUID header, add the trace UUID to a new slogger, and add the
 // trace UUID and new slogger to the context.
 //
+// The trace UUID predates W3C trace context support and is kept for backward
+// compatibility; if UseTracing ran first, the OpenTelemetry trace and span IDs
+// are added to the slogger too.
+//
 // This is intended to be a very early middleware, so much so that it does not
 // check if there is a slogger in the context.
 func UseTraceUUIDAndSlogger(ctx context.Context, slogger *slog.Logger) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// An unparsable trace UUID is rejected, but the problem still gets a
+		// fresh trace UUID so that it can be reported like any other.
 		var traceUUID uuid.UUID
-		var err error
-		if givenUUID := c.GetHeader(TraceUUIDHeader); givenUUID != "" {
-			traceUUID, err = uuid.Parse(givenUUID)
-			if err != nil {
-				c.String(http.StatusBadRequest, "Given a trace UUID that could not be parsed: "+err.Error())
-			}
-		} else {
+		var parseErr error
+		givenUUID := c.GetHeader(TraceUUIDHeader)
+		if givenUUID != "" {
+			traceUUID, parseErr = uuid.Parse(givenUUID)
+		}
+		if givenUUID == "" || parseErr != nil {
+			var err error
 			traceUUID, err = uuid.NewV7()
 			if err != nil {
 				panic("Failed to create a new trace UUID: " + err.Error())
@@ -36,9 +45,19 @@ func UseTraceUUIDAndSlogger(ctx context.Context, slogger *slog.Logger) func(c *g
 		}
 
 		sloggerWithTraceUUID := slogger.With(slog.String("traceUUID", traceUUID.String()))
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+			sloggerWithTraceUUID = sloggerWithTraceUUID.With(
+				slog.String("traceID", spanContext.TraceID().String()),
+				slog.String("spanID", spanContext.SpanID().String()))
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("w1.traceUUID", traceUUID.String()))
+		}
 		sloggerWithTraceUUID.InfoContext(ctx, "Trace UUID has been added to this request's slogger and the context")
 		c.Header(TraceUUIDHeader, traceUUID.String())
 		c.Set(TraceUUIDContextKey, traceUUID)
 		c.Set(sloggerContextKey, sloggerWithTraceUUID)
+
+		if parseErr != nil {
+			problem.Abort(c, http.StatusBadRequest, "Given a trace UUID that could not be parsed: "+parseErr.Error())
+		}
 	}
 }
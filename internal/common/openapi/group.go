@@ -0,0 +1,51 @@
+package openapi
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Group registers routes with gin while documenting them in a Spec.
+type Group struct {
+	gin  *gin.RouterGroup
+	spec *Spec
+}
+
+func NewGroup(spec *Spec, group *gin.RouterGroup) *Group {
+	return &Group{gin: group, spec: spec}
+}
+
+// Group creates a group for routes under relativePath.
+func (g *Group) Group(relativePath string, handlers ...gin.HandlerFunc) *Group {
+	return &Group{gin: g.gin.Group(relativePath, handlers...), spec: g.spec}
+}
+
+// Gin returns the underlying group, for routes that aren't part of the
+// document.
+func (g *Group) Gin() *gin.RouterGroup {
+	return g.gin
+}
+
+func (g *Group) GET(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.handle(http.MethodGet, relativePath, op, handlers)
+}
+
+func (g *Group) POST(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.handle(http.MethodPost, relativePath, op, handlers)
+}
+
+func (g *Group) PUT(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.handle(http.MethodPut, relativePath, op, handlers)
+}
+
+func (g *Group) DELETE(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.handle(http.MethodDelete, relativePath, op, handlers)
+}
+
+func (g *Group) handle(method string, relativePath string, op Operation, handlers []gin.HandlerFunc) {
+	g.gin.Handle(method, relativePath, handlers...)
+	fullPath := path.Join(g.gin.BasePath(), relativePath)
+	g.spec.Add(method, fullPath, op)
+}
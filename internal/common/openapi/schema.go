@@ -0,0 +1,233 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the schema for values of t as they're marshaled by
+// encoding/json. Named structs are added to the components and referenced,
+// anonymous structs are inlined.
+//
+// Struct fields are required unless their json tag has omitempty, and these
+// tags describe them further:
+//
+//   - doc: the field's description
+//   - example: an example value, parsed according to the field's type
+//   - enum: a comma separated list of the allowed values
+func (s *Spec) schemaRef(t reflect.Type) (*openapi3.SchemaRef, error) {
+	switch {
+	case t == timeType:
+		return openapi3.NewDateTimeSchema().NewRef(), nil
+	case t.Kind() == reflect.Pointer:
+		ref, err := s.schemaRef(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(ref), nil
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return s.componentRef(t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return openapi3.NewStringSchema().NewRef(), nil
+	case reflect.Bool:
+		return openapi3.NewBoolSchema().NewRef(), nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewInt64Schema().NewRef(), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return openapi3.NewInt32Schema().NewRef(), nil
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewFloat64Schema().NewRef(), nil
+	case reflect.Interface:
+		return openapi3.NewSchema().NewRef(), nil
+	case reflect.Slice, reflect.Array:
+		items, err := s.schemaRef(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := openapi3.NewArraySchema()
+		schema.Items = items
+		return schema.NewRef(), nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys must be strings, got %s", t)
+		}
+		schema := openapi3.NewObjectSchema()
+		schema.Nullable = true
+		if t.Elem().Kind() == reflect.Interface {
+			schema.AdditionalProperties = openapi3.AdditionalProperties{Has: openapi3.Ptr(true)}
+		} else {
+			values, err := s.schemaRef(t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			schema.AdditionalProperties = openapi3.AdditionalProperties{Schema: values}
+		}
+		return schema.NewRef(), nil
+	case reflect.Struct:
+		schema, err := s.structSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		return schema.NewRef(), nil
+	default:
+		return nil, fmt.Errorf("cannot describe values of %s", t)
+	}
+}
+
+// componentRef adds the schema of the named struct t to the components, if it
+// isn't already, and references it.
+func (s *Spec) componentRef(t reflect.Type) (*openapi3.SchemaRef, error) {
+	name := schemaName(t)
+	if existing, ok := s.schemaTypes[name]; ok && existing != t {
+		return nil, fmt.Errorf("%s and %s would both be named schema %s", existing, t, name)
+	}
+	if _, ok := s.schemaTypes[name]; !ok {
+		// The schema is added before its fields are described so that
+		// recursive types reference themselves instead of looping forever.
+		s.schemaTypes[name] = t
+		s.doc.Components.Schemas[name] = (&openapi3.Schema{}).NewRef()
+		schema, err := s.structSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		*s.doc.Components.Schemas[name].Value = *schema
+	}
+	// References carry the schema too, as if the document were loaded, so
+	// that it can be validated and used without resolving them.
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, s.doc.Components.Schemas[name].Value), nil
+}
+
+// schemaName is t's name, exported, unless it has an entry in schemaNames.
+func schemaName(t reflect.Type) string {
+	if name, ok := schemaNames[t]; ok {
+		return name
+	}
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func (s *Spec) structSchema(t reflect.Type) (*openapi3.Schema, error) {
+	schema := openapi3.NewObjectSchema()
+	if description, ok := schemaDescriptions[t]; ok {
+		schema.Description = description
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, omitEmpty := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		ref, err := s.schemaRef(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t, field.Name, err)
+		}
+		if err := describe(ref, field); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t, field.Name, err)
+		}
+		schema.Properties[name] = ref
+		if !omitEmpty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
+
+// describe applies field's doc, example, and enum tags to ref. Since a
+// referenced schema's siblings are ignored, a described reference is wrapped
+// in an allOf.
+func describe(ref *openapi3.SchemaRef, field reflect.StructField) error {
+	doc, hasDoc := field.Tag.Lookup("doc")
+	example, hasExample := field.Tag.Lookup("example")
+	enum, hasEnum := field.Tag.Lookup("enum")
+	if !hasDoc && !hasExample && !hasEnum {
+		return nil
+	}
+	if ref.Ref != "" {
+		*ref = *openapi3.NewSchemaRef("", &openapi3.Schema{AllOf: openapi3.SchemaRefs{openapi3.NewSchemaRef(ref.Ref, ref.Value)}})
+	}
+
+	schema := ref.Value
+	if hasDoc {
+		schema.Description = doc
+	}
+	if hasExample {
+		v, err := parseTagValue(schema, example)
+		if err != nil {
+			return fmt.Errorf("invalid example: %w", err)
+		}
+		schema.Example = v
+	}
+	if hasEnum {
+		for _, value := range strings.Split(enum, ",") {
+			v, err := parseTagValue(schema, value)
+			if err != nil {
+				return fmt.Errorf("invalid enum: %w", err)
+			}
+			schema.Enum = append(schema.Enum, v)
+		}
+	}
+	return nil
+}
+
+func parseTagValue(schema *openapi3.Schema, value string) (any, error) {
+	switch {
+	case schema.Type.Is(openapi3.TypeInteger):
+		return strconv.ParseInt(value, 10, 64)
+	case schema.Type.Is(openapi3.TypeNumber):
+		return strconv.ParseFloat(value, 64)
+	case schema.Type.Is(openapi3.TypeBoolean):
+		return strconv.ParseBool(value)
+	case schema.Type.Is(openapi3.TypeString):
+		return value, nil
+	default:
+		var v any
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err
+	}
+}
+
+func jsonName(field reflect.StructField) (name string, omitEmpty bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// nullable allows ref's schema to be null as well.
+func nullable(ref *openapi3.SchemaRef) *openapi3.SchemaRef {
+	if ref.Ref != "" {
+		return openapi3.NewSchemaRef("", &openapi3.Schema{
+			Nullable: true,
+			AllOf:    openapi3.SchemaRefs{ref},
+		})
+	}
+	ref.Value.Nullable = true
+	return ref
+}
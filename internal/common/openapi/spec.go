@@ -0,0 +1,348 @@
+// Package openapi builds the web API's OpenAPI document from the routes as
+// they're registered, so that the document can't drift from the handlers and
+// the Go types they respond with.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+	"gopkg.in/yaml.v3"
+)
+
+// The reusable responses every Spec has, for use as Response.Ref.
+const (
+	ResponseBadRequest          = "BadRequest"
+	ResponseInternalServerError = "InternalServerError"
+	ResponseTimeout             = "Timeout"
+)
+
+// schemaNames overrides the component names of types whose Go names don't
+// read well in the document.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(problem.Details{}): "Error",
+}
+
+var schemaDescriptions = map[reflect.Type]string{
+	reflect.TypeOf(problem.Details{}): "RFC 7807 (https://datatracker.ietf.org/doc/html/rfc7807)",
+}
+
+// Info describes the API as a whole.
+type Info struct {
+	Title       string
+	Description string
+	Version     string
+	Servers     []string
+	Tags        []Tag
+}
+
+type Tag struct {
+	Name        string
+	Description string
+}
+
+// Operation describes a route.
+type Operation struct {
+	ID          string
+	Summary     string
+	Description string
+	Tags        []string
+	Params      []Param
+	// RequestBody, if not nil, is a value of the JSON body's type.
+	RequestBody any
+	Responses   []Response
+}
+
+// Param describes a path, query, or header parameter.
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	// Type is a value of the parameter's type, like "" or false.
+	Type    any
+	Example any
+	Default any
+	// Minimum and Maximum bound numeric parameters, and Pattern is a regular
+	// expression string parameters must match.
+	Minimum *float64
+	Maximum *float64
+	Pattern string
+}
+
+// Header describes a response header, which is always a string.
+type Header struct {
+	Name        string
+	Description string
+}
+
+// Response describes one of an operation's responses. Either Ref names a
+// reusable response, or the rest describe it.
+type Response struct {
+	Status      int
+	Ref         string
+	Description string
+	// Body, if not nil, is a value of the JSON body's type.
+	Body any
+	// Problem is true if the body is problem details instead of Body.
+	Problem bool
+	Headers []Header
+}
+
+// Ptr returns a pointer to v, for Param's optional fields.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Spec accumulates the operations of an API. The first error encountered is
+// kept and returned by Build, so registering routes doesn't need to handle
+// errors.
+type Spec struct {
+	doc         *openapi3.T
+	schemaTypes map[string]reflect.Type
+	err         error
+}
+
+func NewSpec(info Info) *Spec {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       info.Title,
+			Description: info.Description,
+			Version:     info.Version,
+		},
+		Paths: openapi3.NewPathsWithCapacity(0),
+		Components: &openapi3.Components{
+			Schemas:   make(openapi3.Schemas),
+			Responses: make(openapi3.ResponseBodies),
+		},
+	}
+	for _, url := range info.Servers {
+		doc.Servers = append(doc.Servers, &openapi3.Server{URL: url})
+	}
+	for _, tag := range info.Tags {
+		doc.Tags = append(doc.Tags, &openapi3.Tag{Name: tag.Name, Description: tag.Description})
+	}
+
+	s := &Spec{doc: doc, schemaTypes: make(map[string]reflect.Type)}
+	s.addProblemResponse(ResponseBadRequest, "Bad Request, the request does not match this spec")
+	s.addProblemResponse(ResponseInternalServerError, "Internal Server Error")
+	s.addProblemResponse(ResponseTimeout, "Service Unavailable, the request ran longer than the server permits")
+	return s
+}
+
+func (s *Spec) addProblemResponse(name string, description string) {
+	response, err := s.response(Response{Description: description, Problem: true})
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.doc.Components.Responses[name] = &openapi3.ResponseRef{Value: response}
+}
+
+var ginParam = regexp.MustCompile(`[:*](\w+)`)
+
+// Add documents the operation at method and path, which may use gin's :param
+// syntax. Operations with parameters or a body are validated by the
+// middleware, so a 400 is documented for them if they don't document one.
+func (s *Spec) Add(method string, path string, op Operation) {
+	path = ginParam.ReplaceAllString(path, "{$1}")
+	operation := openapi3.NewOperation()
+	operation.OperationID = op.ID
+	operation.Summary = op.Summary
+	operation.Description = op.Description
+	operation.Tags = op.Tags
+	operation.Security = &openapi3.SecurityRequirements{{}}
+
+	for _, p := range op.Params {
+		schema, err := s.schemaRef(reflect.TypeOf(p.Type))
+		if err != nil {
+			s.fail(fmt.Errorf("%s %s parameter %s: %w", method, path, p.Name, err))
+			return
+		}
+		schema.Value.Example = p.Example
+		schema.Value.Default = p.Default
+		schema.Value.Min = p.Minimum
+		schema.Value.Max = p.Maximum
+		schema.Value.Pattern = p.Pattern
+		operation.AddParameter(&openapi3.Parameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == openapi3.ParameterInPath,
+			Schema:      schema,
+		})
+	}
+
+	if op.RequestBody != nil {
+		schema, err := s.schemaRef(reflect.TypeOf(op.RequestBody))
+		if err != nil {
+			s.fail(fmt.Errorf("%s %s request body: %w", method, path, err))
+			return
+		}
+		operation.RequestBody = &openapi3.RequestBodyRef{
+			Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(schema),
+		}
+	}
+
+	responses := op.Responses
+	validated := len(op.Params) > 0 || op.RequestBody != nil
+	if validated && !slices.ContainsFunc(responses, func(r Response) bool { return r.Status == http.StatusBadRequest }) {
+		responses = append(slices.Clone(responses), Response{Status: http.StatusBadRequest, Ref: ResponseBadRequest})
+	}
+	operation.Responses = openapi3.NewResponsesWithCapacity(len(responses))
+	for _, r := range responses {
+		ref := &openapi3.ResponseRef{}
+		if r.Ref != "" {
+			component, ok := s.doc.Components.Responses[r.Ref]
+			if !ok {
+				s.fail(fmt.Errorf("%s %s %d response: there is no response named %s", method, path, r.Status, r.Ref))
+				return
+			}
+			ref.Ref = "#/components/responses/" + r.Ref
+			ref.Value = component.Value
+		} else {
+			response, err := s.response(r)
+			if err != nil {
+				s.fail(fmt.Errorf("%s %s %d response: %w", method, path, r.Status, err))
+				return
+			}
+			ref.Value = response
+		}
+		operation.Responses.Set(strconv.Itoa(r.Status), ref)
+	}
+
+	pathItem := s.doc.Paths.Value(path)
+	if pathItem == nil {
+		pathItem = &openapi3.PathItem{}
+		s.doc.Paths.Set(path, pathItem)
+	}
+	if pathItem.GetOperation(method) != nil {
+		s.fail(fmt.Errorf("%s %s is documented twice", method, path))
+		return
+	}
+	pathItem.SetOperation(method, operation)
+}
+
+func (s *Spec) response(r Response) (*openapi3.Response, error) {
+	response := openapi3.NewResponse().WithDescription(r.Description)
+	switch {
+	case r.Problem:
+		schema, err := s.schemaRef(reflect.TypeOf(problem.Details{}))
+		if err != nil {
+			return nil, err
+		}
+		response.Content = openapi3.Content{
+			problem.ContentType: openapi3.NewMediaType().WithSchemaRef(schema),
+		}
+	case r.Body != nil:
+		schema, err := s.schemaRef(reflect.TypeOf(r.Body))
+		if err != nil {
+			return nil, err
+		}
+		response.Content = openapi3.NewContentWithJSONSchemaRef(schema)
+	}
+	if len(r.Headers) > 0 {
+		response.Headers = make(openapi3.Headers, len(r.Headers))
+		for _, h := range r.Headers {
+			response.Headers[h.Name] = &openapi3.HeaderRef{Value: &openapi3.Header{Parameter: openapi3.Parameter{
+				Description: h.Description,
+				Schema:      openapi3.NewStringSchema().NewRef(),
+			}}}
+		}
+	}
+	return response, nil
+}
+
+func (s *Spec) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Build returns the validated document. Further operations must not be
+// added.
+func (s *Spec) Build() (*openapi3.T, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := s.doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("the generated OpenAPI document is invalid: %w", err)
+	}
+	return s.doc, nil
+}
+
+// topLevelOrder is the order the document's top level keys are written in, so
+// it reads from the overview down to the details.
+var topLevelOrder = []string{"openapi", "info", "servers", "tags", "paths", "components"}
+
+// MarshalYAML writes doc as YAML. Keys are sorted, besides the top level
+// ones, so the output is stable.
+func MarshalYAML(doc *openapi3.T) ([]byte, error) {
+	// The document only knows how to marshal itself to JSON, which is YAML,
+	// so it's re-encoded with YAML's block style.
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(j, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) != 1 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the document did not marshal to an object")
+	}
+	clearStyle(&node)
+	root := node.Content[0]
+	root.Content = orderKeys(root.Content, topLevelOrder)
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// orderKeys sorts a mapping node's key-value pairs by their key's index in
+// order, keeping unlisted keys last.
+func orderKeys(pairs []*yaml.Node, order []string) []*yaml.Node {
+	type pair struct{ key, value *yaml.Node }
+	sorted := make([]pair, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		sorted = append(sorted, pair{pairs[i], pairs[i+1]})
+	}
+	rank := func(p pair) int {
+		if i := slices.Index(order, p.key.Value); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	slices.SortStableFunc(sorted, func(a, b pair) int { return rank(a) - rank(b) })
+	ordered := make([]*yaml.Node, 0, len(pairs))
+	for _, p := range sorted {
+		ordered = append(ordered, p.key, p.value)
+	}
+	return ordered
+}
@@ -0,0 +1,132 @@
+// Package problem writes RFC 7807 (https://datatracker.ietf.org/doc/html/rfc7807)
+// problem details responses so that every error returned by the web API has
+// the same shape.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// traceUUIDHeader mirrors middleware.TraceUUIDHeader; middleware cannot be
+// imported here since it writes problems itself.
+const traceUUIDHeader = "X-TRACE-UUID"
+
+// TypeTimeout is the Type of the problem sent when a request runs longer than
+// the server permits, so that clients can tell it apart from other 503s.
+const TypeTimeout = "urn:world-one:problem:timeout"
+
+// Details is the body of a problem response. Type is about:blank unless
+// clients need to tell the problem apart from others with its status, such as
+// TypeTimeout, so Title is the status's text and Detail holds the specifics.
+type Details struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Bad Request"`
+	Status    int    `json:"status" example:"400"`
+	Detail    string `json:"detail,omitempty" example:"Unfortunately, we can’t provide further information."`
+	TraceUUID string `json:"traceUUID,omitempty" example:"0193a9c1-7a4e-7c2b-9a47-1f2b9d1e6a3c" doc:"The request's X-TRACE-UUID, useful when reporting issues."`
+	// Violations lists every reason the request was invalid, if the problem
+	// was caused by request validation.
+	Violations []Violation `json:"violations,omitempty" doc:"Every way the request does not match this spec, when that is why it is a 400."`
+}
+
+// Violation is one way a request failed to match the API's spec. In is where
+// the offending value was (path, query, header, or body), and Name is the
+// parameter's name or, for bodies, the JSON pointer to the value.
+type Violation struct {
+	In     string `json:"in" enum:"path,query,header,cookie,body,request" example:"query"`
+	Name   string `json:"name,omitempty" example:"newEraName" doc:"The parameter's name, or the JSON pointer to the offending value in the body."`
+	Reason string `json:"reason" example:"value is required but missing"`
+}
+
+func New(status int, detail string, traceUUID string) Details {
+	return Details{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		TraceUUID: traceUUID,
+	}
+}
+
+// Mapping associates an error (checked via errors.Is) with the status and
+// detail to respond with.
+type Mapping struct {
+	Err    error
+	Status int
+	Detail string
+}
+
+// Abort writes a problem response and aborts the rest of the gin handler
+// chain. The trace UUID is read from the response headers, so this should be
+// called after middleware.UseTraceUUIDAndSlogger has run.
+func Abort(c *gin.Context, status int, detail string) {
+	details := New(status, detail, c.Writer.Header().Get(traceUUIDHeader))
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, details)
+}
+
+// AbortWithViolations is like Abort but also lists why the request was
+// invalid.
+func AbortWithViolations(c *gin.Context, status int, detail string, violations []Violation) {
+	details := New(status, detail, c.Writer.Header().Get(traceUUIDHeader))
+	details.Violations = violations
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, details)
+}
+
+// AbortWithErr will respond with the first mapping whose Err matches err. If
+// none match, a 500 is returned without exposing err.
+func AbortWithErr(c *gin.Context, err error, mappings []Mapping) {
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			Abort(c, m.Status, m.Detail)
+			return
+		}
+	}
+	Abort(c, http.StatusInternalServerError, "An unexpected error occurred")
+}
+
+// IsMapped returns true if err matches any of mappings; this is useful to know
+// if an error is expected or worth logging.
+func IsMapped(err error, mappings []Mapping) bool {
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes a problem response to a plain http.ResponseWriter, for use
+// outside of gin.
+func Write(w http.ResponseWriter, details Details) error {
+	body, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(details.Status)
+	_, err = w.Write(body)
+	return err
+}
+
+// NoRoute is a gin.Engine.NoRoute handler, so that requests to unknown paths
+// get a problem rather than gin's plain text 404.
+func NoRoute(c *gin.Context) {
+	Abort(c, http.StatusNotFound, "There is no resource at "+c.Request.URL.Path)
+}
+
+// NoMethod is a gin.Engine.NoMethod handler, so that requests using the wrong
+// method get a problem rather than gin's plain text 405. Gin has already set
+// the Allow header.
+func NoMethod(c *gin.Context) {
+	Abort(c, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed for %s, use %s",
+		c.Request.Method, c.Request.URL.Path, c.Writer.Header().Get("Allow")))
+}
@@ -0,0 +1,34 @@
+package common
+
+// ringBuffer holds the most recent values up to its capacity, overwriting the
+// oldest once full. It is not safe for concurrent use.
+type ringBuffer[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+func newRingBuffer[T any](capacity int) *ringBuffer[T] {
+	return &ringBuffer[T]{values: make([]T, capacity)}
+}
+
+func (b *ringBuffer[T]) push(value T) {
+	if len(b.values) == 0 {
+		return
+	}
+	b.values[b.next] = value
+	b.next = (b.next + 1) % len(b.values)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// slice returns a copy of the values from oldest to newest.
+func (b *ringBuffer[T]) slice() []T {
+	if !b.full {
+		return append([]T(nil), b.values[:b.next]...)
+	}
+	out := make([]T, 0, len(b.values))
+	out = append(out, b.values[b.next:]...)
+	return append(out, b.values[:b.next]...)
+}
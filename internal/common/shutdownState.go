@@ -0,0 +1,18 @@
+package common
+
+import "sync/atomic"
+
+// ShutdownState tracks if graceful shutdown has begun so that readiness can
+// fail (and traffic be drained) before the server stops accepting requests.
+// The zero value is ready to use.
+type ShutdownState struct {
+	shuttingDown atomic.Bool
+}
+
+func (s *ShutdownState) Begin() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *ShutdownState) ShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
+)
+
+// TxBeginner is satisfied by *pgxpool.Pool and *pgx.Conn.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+type TxOptions struct {
+	IsoLevel pgx.TxIsoLevel
+	// MaxAttempts is optional, defaulting to 5.
+	MaxAttempts int
+	// BaseBackoff is optional, defaulting to 20ms. Each retry waits a random
+	// duration up to BaseBackoff doubled per previous attempt, capped at
+	// MaxBackoff.
+	BaseBackoff time.Duration
+	// MaxBackoff is optional, defaulting to 1s.
+	MaxBackoff time.Duration
+}
+
+// RunInTx runs fn in a transaction and commits it. If fn or the commit fail
+// with a serialization failure or deadlock, the transaction is rolled back and
+// fn is run again in a new transaction after a jittered backoff, so fn must
+// not have side effects outside of tx.
+//
+// Retries stop early when ctx would expire before the next attempt. If every
+// attempt conflicted, the returned error wraps ErrTxConflict and the last
+// conflict. Any other error from fn is returned as is.
+func RunInTx(
+	ctx context.Context,
+	beginner TxBeginner,
+	slogger *slog.Logger,
+	opts TxOptions,
+	fn func(tx pgx.Tx) error,
+) error {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 20 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Second
+	}
+
+	backoffCap := opts.BaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := runTxAttempt(ctx, beginner, slogger, opts.IsoLevel, fn)
+		if err == nil {
+			if attempt > 1 {
+				slogger.InfoContext(ctx, "Transaction committed after retrying", slog.Int("attempt", attempt))
+			}
+			return nil
+		}
+		sqlState, retryable := txConflictCode(err)
+		if !retryable {
+			return err
+		}
+		if attempt == opts.MaxAttempts {
+			slogger.ErrorContext(ctx, "Transaction conflicted on every attempt, giving up",
+				slog.Int("attempt", attempt),
+				slog.String("sqlState", sqlState),
+				slog.String("err", err.Error()))
+			return fmt.Errorf("%w after %d attempts: %w", ErrTxConflict, attempt, err)
+		}
+
+		backoff := rand.N(backoffCap) + 1
+		backoffCap = min(backoffCap*2, opts.MaxBackoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			slogger.ErrorContext(ctx, "Transaction conflicted and the context expires before it can be retried",
+				slog.Int("attempt", attempt),
+				slog.String("sqlState", sqlState),
+				slog.String("err", err.Error()))
+			return fmt.Errorf("%w after %d attempts: %w", ErrTxConflict, attempt, err)
+		}
+		slogger.WarnContext(ctx, "Transaction conflicted, retrying",
+			slog.Int("attempt", attempt),
+			slog.String("sqlState", sqlState),
+			slog.String("retryIn", backoff.String()),
+			slog.String("err", err.Error()))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("transaction retry was abandoned: %w", ctx.Err())
+		}
+	}
+}
+
+func runTxAttempt(
+	ctx context.Context,
+	beginner TxBeginner,
+	slogger *slog.Logger,
+	isoLevel pgx.TxIsoLevel,
+	fn func(tx pgx.Tx) error,
+) error {
+	tx, err := beginner.BeginTx(ctx, pgx.TxOptions{IsoLevel: isoLevel})
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slogger.ErrorContext(ctx, "Transaction rollback failed unexpectedly", slog.String("err", err.Error()))
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// txConflictCode returns err's SQLSTATE if it is a serialization failure or
+// deadlock, which can succeed when retried.
+func txConflictCode(err error) (string, bool) {
+	if !dberr.IsRetryable(err) {
+		return "", false
+	}
+	var classified *dberr.Error
+	errors.As(dberr.Classify(err), &classified)
+	return classified.SQLState, true
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
+)
+
+// fakeBeginner begins fakeTxs, whose commits fail with commitErrs in order
+// and then succeed.
+type fakeBeginner struct {
+	begun      int
+	committed  int
+	rolledBack int
+	commitErrs []error
+}
+
+func (b *fakeBeginner) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	b.begun++
+	return &fakeTx{beginner: b}, nil
+}
+
+// fakeTx only supports committing and rolling back; the embedded pgx.Tx is
+// nil, so anything else panics.
+type fakeTx struct {
+	pgx.Tx
+	beginner *fakeBeginner
+	closed   bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	if len(tx.beginner.commitErrs) > 0 {
+		err := tx.beginner.commitErrs[0]
+		tx.beginner.commitErrs = tx.beginner.commitErrs[1:]
+		return err
+	}
+	tx.beginner.committed++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.closed {
+		return pgx.ErrTxClosed
+	}
+	tx.closed = true
+	tx.beginner.rolledBack++
+	return nil
+}
+
+var (
+	serializationFailure = &pgconn.PgError{Code: "40001", Message: "could not serialize access due to concurrent update"}
+	deadlock             = &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+)
+
+func discardSlogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fastRetries keeps the tests from waiting on backoffs.
+var fastRetries = TxOptions{IsoLevel: pgx.Serializable, BaseBackoff: time.Microsecond, MaxBackoff: time.Microsecond}
+
+func TestRunInTxRetriesConflicts(t *testing.T) {
+	beginner := &fakeBeginner{commitErrs: []error{deadlock}}
+	attempts := 0
+	err := RunInTx(context.Background(), beginner, discardSlogger(), fastRetries, func(pgx.Tx) error {
+		attempts++
+		if attempts == 1 {
+			return serializationFailure
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first attempt failed in fn, the second at commit.
+	if attempts != 3 || beginner.begun != 3 || beginner.committed != 1 || beginner.rolledBack != 1 {
+		t.Fatalf("Expected a conflict in fn and one at commit to be retried, got %d attempts and %+v", attempts, beginner)
+	}
+}
+
+func TestRunInTxGivesUpAfterMaxAttempts(t *testing.T) {
+	for _, conflict := range []error{serializationFailure, deadlock} {
+		t.Run(conflict.(*pgconn.PgError).Code, func(t *testing.T) {
+			beginner := &fakeBeginner{}
+			opts := fastRetries
+			opts.MaxAttempts = 3
+			attempts := 0
+			err := RunInTx(context.Background(), beginner, discardSlogger(), opts, func(pgx.Tx) error {
+				attempts++
+				return conflict
+			})
+			if !errors.Is(err, ErrTxConflict) || !dberr.IsRetryable(err) {
+				t.Fatalf("Expected ErrTxConflict wrapping the conflict, got %v", err)
+			}
+			if attempts != 3 || beginner.rolledBack != 3 {
+				t.Fatalf("Expected 3 rolled back attempts, got %d attempts and %+v", attempts, beginner)
+			}
+		})
+	}
+}
+
+func TestRunInTxDefaultsToFiveAttempts(t *testing.T) {
+	attempts := 0
+	err := RunInTx(context.Background(), &fakeBeginner{}, discardSlogger(), fastRetries, func(pgx.Tx) error {
+		attempts++
+		return serializationFailure
+	})
+	if !errors.Is(err, ErrTxConflict) || attempts != 5 {
+		t.Fatalf("Expected ErrTxConflict after 5 attempts, got %v after %d", err, attempts)
+	}
+}
+
+func TestRunInTxDoesNotRetryOtherErrors(t *testing.T) {
+	for _, fnErr := range []error{
+		errors.New("not a database error"),
+		&pgconn.PgError{Code: "23505", ConstraintName: "eras_name_key"},
+	} {
+		beginner := &fakeBeginner{}
+		attempts := 0
+		err := RunInTx(context.Background(), beginner, discardSlogger(), fastRetries, func(pgx.Tx) error {
+			attempts++
+			return fnErr
+		})
+		if err != fnErr || errors.Is(err, ErrTxConflict) {
+			t.Fatalf("Expected %v to be returned as is, got %v", fnErr, err)
+		}
+		if attempts != 1 || beginner.rolledBack != 1 {
+			t.Fatalf("Expected a single rolled back attempt, got %d attempts and %+v", attempts, beginner)
+		}
+	}
+}
+
+func TestRunInTxDoesNotBackOffPastTheDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	opts := TxOptions{IsoLevel: pgx.Serializable, BaseBackoff: time.Hour, MaxBackoff: time.Hour}
+
+	start := time.Now()
+	err := RunInTx(ctx, &fakeBeginner{}, discardSlogger(), opts, func(pgx.Tx) error {
+		return serializationFailure
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected to give up rather than back off past the deadline, took %s", elapsed)
+	}
+	if !errors.Is(err, ErrTxConflict) {
+		t.Fatalf("Expected ErrTxConflict, got %v", err)
+	}
+}
@@ -88,6 +88,53 @@ func (q *Queries) GetEras(ctx context.Context) ([]Era, error) {
 	return items, nil
 }
 
+const getErasPage = `-- name: GetErasPage :many
+select id, name, start_time, end_time, create_time, update_time
+from eras
+where id > $1
+order by id
+limit $2
+`
+
+type GetErasPageParams struct {
+	AfterID  int64
+	PageSize int32
+}
+
+// GetErasPage
+//
+//	select id, name, start_time, end_time, create_time, update_time
+//	from eras
+//	where id > $1
+//	order by id
+//	limit $2
+func (q *Queries) GetErasPage(ctx context.Context, arg GetErasPageParams) ([]Era, error) {
+	rows, err := q.db.Query(ctx, getErasPage, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Era
+	for rows.Next() {
+		var i Era
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CreateTime,
+			&i.UpdateTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const insertEra = `-- name: InsertEra :one
 insert into eras (name, start_time, end_time)
 values           ($1,   $2,         $3)
@@ -8,12 +8,12 @@ import (
 )
 
 type EraDTO struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	StartTime  time.Time `json:"startTime"`
-	EndTime    time.Time `json:"endTime"`
-	CreateTime time.Time `json:"createTime"`
-	UpdateTime time.Time `json:"updateTime"`
+	ID         string    `json:"id" example:"0"`
+	Name       string    `json:"name" example:"The first Era"`
+	StartTime  time.Time `json:"startTime" example:"2024-12-09T02:48:40.246181Z"`
+	EndTime    time.Time `json:"endTime" example:"2200-01-01T00:00:00Z" doc:"2200-01-01T00:00:00Z if this is the current era."`
+	CreateTime time.Time `json:"createTime" example:"2024-12-09T02:48:40.246181Z"`
+	UpdateTime time.Time `json:"updateTime" example:"2024-12-09T02:48:40.246181Z"`
 }
 
 func MakeEraDTO(era db.Era) EraDTO {
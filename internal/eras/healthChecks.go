@@ -1,22 +1,22 @@
 package eras
 
 import (
+	"context"
 	"log/slog"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sawyerwatts/world-one/internal/common"
-	"github.com/sawyerwatts/world-one/internal/db"
 )
 
-func AppendHealthChecks(checks []common.HealthCheck, dbPool *pgxpool.Pool) []common.HealthCheck {
+func AppendHealthChecks(checks []common.HealthCheck, store Store) []common.HealthCheck {
 	return append(checks,
 		common.HealthCheck{
-			Name: "Assert current era exists",
-			Check: func(c *gin.Context, slogger *slog.Logger) common.HealthCheckResult {
-				dbQueries := db.New(dbPool)
-				eraQueries := MakeQueries(dbQueries, slogger)
-				currEra, err := eraQueries.GetCurrEra(c)
+			Name:    "Assert current era exists",
+			Probes:  common.ProbeReadiness,
+			Timeout: 2 * time.Second,
+			Check: func(ctx context.Context, slogger *slog.Logger) common.HealthCheckResult {
+				eraQueries := MakeQueries(store, slogger)
+				currEra, err := eraQueries.GetCurrEra(ctx)
 				if err != nil {
 					return common.HealthCheckResult{
 						Status:  common.HealthStatusUnhealthy,
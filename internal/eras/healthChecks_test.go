@@ -0,0 +1,28 @@
+package eras
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+)
+
+func TestCurrEraHealthCheck(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		checks := AppendHealthChecks(nil, store)
+		if len(checks) != 1 {
+			t.Fatalf("Expected 1 health check, got %d", len(checks))
+		}
+
+		if result := checks[0].Check(ctx, discardSlogger()); result.Status != common.HealthStatusUnhealthy {
+			t.Fatalf("Expected unhealthy without a current era, got %s", result.Status)
+		}
+		mustInsertEra(t, store, "Current", time.Now().UTC(), common.UninitializedEndDate)
+		if result := checks[0].Check(ctx, discardSlogger()); result.Status != common.HealthStatusHealthy {
+			t.Fatalf("Expected healthy with a current era, got %s: %v", result.Status, result.Payload)
+		}
+	})
+}
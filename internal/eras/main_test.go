@@ -0,0 +1,37 @@
+package eras
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	pgtest.Main(m)
+}
+
+func discardSlogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// forEachStore runs test as parallel subtests against a MemStore and a
+// Postgres-backed store, the latter being skipped if Postgres is unavailable.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Helper()
+	stores := []struct {
+		name     string
+		newStore func(t *testing.T) Store
+	}{
+		{"mem", func(*testing.T) Store { return NewMemStore(clock.System{}) }},
+		{"pg", func(t *testing.T) Store { return NewPgStore(pgtest.NewPool(t)) }},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+			test(t, s.newStore(t))
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package eras
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
+	"github.com/sawyerwatts/world-one/internal/db"
+)
+
+// singleOpenEraConstraint is the partial unique index ensuring only the
+// current era has the uninitialized end date.
+const singleOpenEraConstraint = "eras_single_open_era"
+
+// memStoreTxAttempts matches common.RunInTx's default attempts.
+const memStoreTxAttempts = 5
+
+// MemStore is an in-memory Store for tests. Like the schema, it enforces
+// unique names, a single open era, and UpdateEra's optimistic check of
+// update_time, returning the same errors Postgres would.
+//
+// Transactions are run one at a time against a copy of the eras. If the eras
+// were changed outside of the transaction before it commits, the commit fails
+// with a serialization failure and the transaction is retried, like
+// common.RunInTx, up to memStoreTxAttempts times.
+type MemStore struct {
+	clock clock.Clock
+
+	// txMu serializes transactions.
+	txMu sync.Mutex
+
+	// mu guards the fields below.
+	mu      sync.Mutex
+	eras    []db.Era
+	nextID  int64
+	version int
+	inTx    bool
+}
+
+// NewMemStore creates an empty store whose create and update times are read
+// from clk.
+func NewMemStore(clk clock.Clock) *MemStore {
+	return &MemStore{clock: clk, nextID: 1}
+}
+
+func (s *MemStore) GetCurrEra(ctx context.Context) (db.Era, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, era := range s.eras {
+		if era.EndTime.Equal(common.UninitializedEndDate) {
+			return era, nil
+		}
+	}
+	return db.Era{}, pgx.ErrNoRows
+}
+
+func (s *MemStore) GetEras(ctx context.Context) ([]db.Era, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.eras), nil
+}
+
+func (s *MemStore) GetErasPage(ctx context.Context, arg db.GetErasPageParams) ([]db.Era, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page := make([]db.Era, 0, arg.PageSize)
+	// Eras are appended in ID order.
+	for _, era := range s.eras {
+		if len(page) == int(arg.PageSize) {
+			break
+		}
+		if era.ID > arg.AfterID {
+			page = append(page, era)
+		}
+	}
+	return page, nil
+}
+
+func (s *MemStore) InsertEra(ctx context.Context, arg db.InsertEraParams) (db.Era, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	era := db.Era{
+		ID:         s.nextID,
+		Name:       arg.Name,
+		StartTime:  arg.StartTime.Truncate(time.Microsecond),
+		EndTime:    arg.EndTime.Truncate(time.Microsecond),
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err := s.checkConstraintsLocked(era); err != nil {
+		return db.Era{}, err
+	}
+	s.nextID++
+	s.eras = append(s.eras, era)
+	s.version++
+	return era, nil
+}
+
+func (s *MemStore) UpdateEra(ctx context.Context, arg db.UpdateEraParams) (db.Era, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.eras, func(era db.Era) bool {
+		return era.ID == arg.ID && era.UpdateTime.Equal(arg.UpdateTime)
+	})
+	if i < 0 {
+		return db.Era{}, pgx.ErrNoRows
+	}
+	era := s.eras[i]
+	era.Name = arg.Name
+	era.StartTime = arg.StartTime.Truncate(time.Microsecond)
+	era.EndTime = arg.EndTime.Truncate(time.Microsecond)
+	era.UpdateTime = s.now()
+	if err := s.checkConstraintsLocked(era); err != nil {
+		return db.Era{}, err
+	}
+	s.eras[i] = era
+	s.version++
+	return era, nil
+}
+
+func (s *MemStore) TruncateEra(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := int64(len(s.eras))
+	s.eras = nil
+	s.version++
+	return deleted, nil
+}
+
+func (s *MemStore) InSerializableTx(ctx context.Context, slogger *slog.Logger, fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.runTxAttempt(fn)
+		if !dberr.IsRetryable(err) {
+			return err
+		}
+		if attempt == memStoreTxAttempts {
+			slogger.ErrorContext(ctx, "Transaction conflicted on every attempt, giving up",
+				slog.Int("attempt", attempt),
+				slog.String("err", err.Error()))
+			return fmt.Errorf("%w after %d attempts: %w", common.ErrTxConflict, attempt, err)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("transaction retry was abandoned: %w", ctx.Err())
+		}
+		slogger.WarnContext(ctx, "Transaction conflicted, retrying",
+			slog.Int("attempt", attempt),
+			slog.String("err", err.Error()))
+	}
+}
+
+// runTxAttempt runs fn against a copy of the eras, committing the copy if fn
+// succeeds and the eras weren't changed in the meantime.
+func (s *MemStore) runTxAttempt(fn func(tx Store) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	tx := &MemStore{
+		clock:  s.clock,
+		eras:   slices.Clone(s.eras),
+		nextID: s.nextID,
+		inTx:   true,
+	}
+	version := s.version
+	s.mu.Unlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.version != version {
+		return &pgconn.PgError{
+			Severity: "ERROR",
+			Code:     "40001",
+			Message:  "could not serialize access due to concurrent update",
+		}
+	}
+	s.eras = tx.eras
+	s.nextID = tx.nextID
+	s.version++
+	return nil
+}
+
+// checkConstraintsLocked returns the unique violation era would cause when
+// saved, if any.
+func (s *MemStore) checkConstraintsLocked(era db.Era) error {
+	for _, other := range s.eras {
+		if other.ID == era.ID {
+			continue
+		}
+		if other.Name == era.Name {
+			return uniqueViolation(eraNameUniqueConstraint, fmt.Sprintf("Key (name)=(%s) already exists.", era.Name))
+		}
+		if other.EndTime.Equal(common.UninitializedEndDate) && era.EndTime.Equal(common.UninitializedEndDate) {
+			return uniqueViolation(singleOpenEraConstraint, "Key ((true))=(t) already exists.")
+		}
+	}
+	return nil
+}
+
+func uniqueViolation(constraint string, detail string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		Detail:         detail,
+		TableName:      "eras",
+		ConstraintName: constraint,
+	}
+}
+
+// now matches the precision of Postgres' timestamptz.
+func (s *MemStore) now() time.Time {
+	return s.clock.Now().UTC().Truncate(time.Microsecond)
+}
@@ -2,18 +2,22 @@ package eras
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
 	"github.com/sawyerwatts/world-one/internal/db"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // TODO: impl caching
 
 var ErrNoCurrEra = errors.New("there is no current era, the game is misconfigured")
 
+var tracer = otel.Tracer("github.com/sawyerwatts/world-one/internal/eras")
+
 type Queries struct {
 	dbQueries queriesDBQueries
 	slogger   *slog.Logger
@@ -32,16 +36,24 @@ func MakeQueries(
 type queriesDBQueries interface {
 	GetCurrEra(ctx context.Context) (db.Era, error)
 	GetEras(ctx context.Context) ([]db.Era, error)
+	GetErasPage(ctx context.Context, arg db.GetErasPageParams) ([]db.Era, error)
 }
 
 // GetCurrEra can return ErrNoCurrEra.
 func (q Queries) GetCurrEra(ctx context.Context) (db.Era, error) {
+	ctx, span := tracer.Start(ctx, "eras.Queries.GetCurrEra")
+	defer span.End()
+
 	q.slogger.InfoContext(ctx, "Retrieving current era")
 	currEra, err := q.dbQueries.GetCurrEra(ctx)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		err = dberr.Classify(err)
+		if errors.Is(err, dberr.ErrNoRows) {
+			span.SetStatus(codes.Error, ErrNoCurrEra.Error())
 			return db.Era{}, ErrNoCurrEra
 		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return db.Era{}, fmt.Errorf("era queries failed to retrieve current era: %w", err)
 	}
 	q.slogger.InfoContext(ctx, "Retrieved current era")
@@ -49,11 +61,33 @@ func (q Queries) GetCurrEra(ctx context.Context) (db.Era, error) {
 }
 
 func (q Queries) GetEras(ctx context.Context) ([]db.Era, error) {
+	ctx, span := tracer.Start(ctx, "eras.Queries.GetEras")
+	defer span.End()
+
 	q.slogger.InfoContext(ctx, "Retrieving eras")
 	allEras, err := q.dbQueries.GetEras(ctx)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("era queries failed to retrieve all eras: %w", err)
 	}
 	q.slogger.InfoContext(ctx, "Retrieved eras")
 	return allEras, nil
 }
+
+// GetErasPage returns up to pageSize eras with IDs after afterID, ordered by
+// ID. Pass an afterID of 0 for the first page.
+func (q Queries) GetErasPage(ctx context.Context, afterID int64, pageSize int32) ([]db.Era, error) {
+	ctx, span := tracer.Start(ctx, "eras.Queries.GetErasPage")
+	defer span.End()
+
+	q.slogger.InfoContext(ctx, "Retrieving a page of eras", slog.Int64("afterID", afterID), slog.Int("pageSize", int(pageSize)))
+	page, err := q.dbQueries.GetErasPage(ctx, db.GetErasPageParams{AfterID: afterID, PageSize: pageSize})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("era queries failed to retrieve a page of eras: %w", err)
+	}
+	q.slogger.InfoContext(ctx, "Retrieved a page of eras", slog.Int("count", len(page)))
+	return page, nil
+}
@@ -0,0 +1,76 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/db"
+)
+
+func TestGetCurrEraWithoutErasReturnsErrNoCurrEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		eraQueries := MakeQueries(store, discardSlogger())
+
+		_, err := eraQueries.GetCurrEra(context.Background())
+		if !errors.Is(err, ErrNoCurrEra) {
+			t.Fatalf("Expected ErrNoCurrEra, got %v", err)
+		}
+	})
+}
+
+func TestGetCurrEraReturnsTheUnendedEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := store.InsertEra(ctx, db.InsertEraParams{Name: "Ended", StartTime: start, EndTime: start.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		curr, err := store.InsertEra(ctx, db.InsertEraParams{Name: "Current", StartTime: start.Add(time.Hour), EndTime: common.UninitializedEndDate})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := MakeQueries(store, discardSlogger()).GetCurrEra(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != curr.ID || got.Name != "Current" {
+			t.Fatalf("Expected era %d (Current), got %d (%s)", curr.ID, got.ID, got.Name)
+		}
+	})
+}
+
+func TestGetErasReturnsEveryEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		eraQueries := MakeQueries(store, discardSlogger())
+
+		allEras, err := eraQueries.GetEras(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(allEras) != 0 {
+			t.Fatalf("Expected no eras, got %d", len(allEras))
+		}
+
+		now := time.Now().UTC()
+		for _, name := range []string{"First", "Second"} {
+			if _, err := store.InsertEra(ctx, db.InsertEraParams{Name: name, StartTime: now, EndTime: now}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		allEras, err = eraQueries.GetEras(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(allEras) != 2 {
+			t.Fatalf("Expected 2 eras, got %d", len(allEras))
+		}
+	})
+}
@@ -9,16 +9,23 @@ import (
 	"time"
 	"unicode"
 
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
 	"github.com/sawyerwatts/world-one/internal/db"
 )
 
 var (
 	ErrWhitespaceEraName = errors.New("era name is whitespace")
 	ErrDuplicateEraName  = errors.New("era name is a duplicate")
+	// ErrRolloverBeforeCurrEra is returned when rolling over at a time before
+	// the current era started, such as after the game clock was moved back.
+	ErrRolloverBeforeCurrEra = errors.New("rollover is before the current era started")
 )
 
+// eraNameUniqueConstraint is Postgres' default name for the unique constraint
+// on eras.name.
+const eraNameUniqueConstraint = "eras_name_key"
+
 type rolloverDBQueries interface {
 	InsertEra(ctx context.Context, arg db.InsertEraParams) (db.Era, error)
 	UpdateEra(ctx context.Context, arg db.UpdateEraParams) (db.Era, error)
@@ -27,7 +34,9 @@ type rolloverDBQueries interface {
 // Rollover is used to terminate the previous Era (if one exists) while creating
 // the next Era. While this Rollover occurs, other parts of the game will likely
 // be soft reset as well; because of this, the Eras cannot be rolled over before
-// the actual start time of the new Era.
+// the actual start time of the new Era. Likewise, rolling over at a time
+// before the current era started returns ErrRolloverBeforeCurrEra rather than
+// saving an era that ends before it starts.
 //
 // If newEraName has leading or trailing whitespace, that will be removed.
 func Rollover(
@@ -65,6 +74,14 @@ func Rollover(
 		}
 	}
 
+	if hasCurrEra && now.Before(currEra.StartTime) {
+		slogger.ErrorContext(ctx, "Cannot roll over before the current era started",
+			slog.Time("now", now),
+			slog.Time("currEraStartTime", currEra.StartTime))
+		return db.Era{}, nil, fmt.Errorf("%w: it started at %s, but it is %s",
+			ErrRolloverBeforeCurrEra, currEra.StartTime.Format(time.RFC3339), now.Format(time.RFC3339))
+	}
+
 	if hasCurrEra {
 		slogger.InfoContext(ctx, "There is a current era, terminating and updating database")
 		currEra.EndTime = now
@@ -79,7 +96,8 @@ func Rollover(
 			return db.Era{}, nil, fmt.Errorf("short circuiting era rollover, context has error: %w", err)
 		}
 		if err != nil {
-			if err.Error() == common.PsqlErrorMessageNoRows {
+			err = dberr.Classify(err)
+			if errors.Is(err, dberr.ErrNoRows) {
 				slogger.ErrorContext(ctx, "Failed to update the current era due to no rows returned; assuming a stale updated_time was used", slog.String("err", err.Error()))
 				return db.Era{}, nil, common.ErrStaleDBInput
 			}
@@ -99,11 +117,15 @@ func Rollover(
 		return db.Era{}, nil, fmt.Errorf("short circuiting era rollover, context has error: %w", err)
 	}
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == common.PgErrorCodeUniqueViolation {
-			slogger.ErrorContext(ctx, "given era name is a duplicate", slog.String("givenEraName", newEraName), slog.String("err", pgErr.Error()))
+		err = dberr.Classify(err)
+		if errors.Is(err, dberr.ErrUniqueViolation) && dberr.Constraint(err) == eraNameUniqueConstraint {
+			slogger.ErrorContext(ctx, "given era name is a duplicate", slog.String("givenEraName", newEraName), slog.String("err", err.Error()))
 			return db.Era{}, nil, ErrDuplicateEraName
 		}
+		if errors.Is(err, dberr.ErrUniqueViolation) && dberr.Constraint(err) == singleOpenEraConstraint {
+			slogger.ErrorContext(ctx, "Another era was concurrently opened", slog.String("err", err.Error()))
+			return db.Era{}, nil, common.ErrStaleDBInput
+		}
 		return db.Era{}, nil, fmt.Errorf("era rollover failed while inserting the new era: %w", err)
 	}
 	slogger.InfoContext(ctx, "New era was saved")
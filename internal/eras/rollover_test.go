@@ -0,0 +1,115 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/db"
+)
+
+func TestRolloverCreatesTheFirstEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		newEra, prevEra, err := Rollover(ctx, MakeQueries(store, slogger), store, slogger, now, "  First  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prevEra != nil {
+			t.Fatalf("Expected no previous era, got %+v", prevEra)
+		}
+		if newEra.Name != "First" {
+			t.Fatalf("Expected the name to be trimmed to First, got %q", newEra.Name)
+		}
+		if !newEra.StartTime.Equal(now) || !newEra.EndTime.Equal(common.UninitializedEndDate) {
+			t.Fatalf("Expected the era to start at %s and be unended, got %s to %s", now, newEra.StartTime, newEra.EndTime)
+		}
+	})
+}
+
+func TestRolloverEndsTheCurrentEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		eraQueries := MakeQueries(store, slogger)
+		first := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		second := first.Add(24 * time.Hour)
+
+		firstEra, _, err := Rollover(ctx, eraQueries, store, slogger, first, "First")
+		if err != nil {
+			t.Fatal(err)
+		}
+		secondEra, prevEra, err := Rollover(ctx, eraQueries, store, slogger, second, "Second")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prevEra == nil || prevEra.ID != firstEra.ID || !prevEra.EndTime.Equal(second) {
+			t.Fatalf("Expected era %d to have ended at %s, got %+v", firstEra.ID, second, prevEra)
+		}
+
+		curr, err := eraQueries.GetCurrEra(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if curr.ID != secondEra.ID {
+			t.Fatalf("Expected the current era to be %d, got %d", secondEra.ID, curr.ID)
+		}
+	})
+}
+
+func TestRolloverRejectsInvalidNames(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		eraQueries := MakeQueries(store, slogger)
+		now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		if _, _, err := Rollover(ctx, eraQueries, store, slogger, now, " \t"); !errors.Is(err, ErrWhitespaceEraName) {
+			t.Fatalf("Expected ErrWhitespaceEraName, got %v", err)
+		}
+		if _, _, err := Rollover(ctx, eraQueries, store, slogger, now, "Taken"); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := Rollover(ctx, eraQueries, store, slogger, now.Add(time.Hour), "Taken"); !errors.Is(err, ErrDuplicateEraName) {
+			t.Fatalf("Expected ErrDuplicateEraName, got %v", err)
+		}
+	})
+}
+
+// staleCurrEraQueries returns the current era as it was before being
+// concurrently modified.
+type staleCurrEraQueries struct {
+	Store
+}
+
+func (q staleCurrEraQueries) GetCurrEra(ctx context.Context) (db.Era, error) {
+	era, err := q.Store.GetCurrEra(ctx)
+	era.UpdateTime = era.UpdateTime.Add(-time.Second)
+	return era, err
+}
+
+func TestRolloverRejectsAStaleCurrentEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+		if _, _, err := Rollover(ctx, MakeQueries(store, slogger), store, slogger, now, "First"); err != nil {
+			t.Fatal(err)
+		}
+		staleQueries := MakeQueries(staleCurrEraQueries{store}, slogger)
+		_, _, err := Rollover(ctx, staleQueries, store, slogger, now.Add(time.Hour), "Second")
+		if !errors.Is(err, common.ErrStaleDBInput) {
+			t.Fatalf("Expected ErrStaleDBInput, got %v", err)
+		}
+	})
+}
@@ -2,121 +2,255 @@ package eras
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
 	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
 	"github.com/sawyerwatts/world-one/internal/db"
 )
 
+var problemMappings = []problem.Mapping{
+	{
+		Err:    ErrWhitespaceEraName,
+		Status: http.StatusBadRequest,
+		Detail: "Expected query parameter newEraName to not be blank",
+	},
+	{
+		Err:    ErrDuplicateEraName,
+		Status: http.StatusBadRequest,
+		Detail: "The given new era's name is a duplicate of another pre-existing era",
+	},
+	{
+		Err:    ErrNoCurrEra,
+		Status: http.StatusInternalServerError,
+		Detail: "There is no current era, the game is not initialized yet",
+	},
+	{
+		Err:    common.ErrStaleDBInput,
+		Status: http.StatusConflict,
+		Detail: "The current era was modified while rolling over, try again",
+	},
+	{
+		Err:    ErrRolloverBeforeCurrEra,
+		Status: http.StatusConflict,
+		Detail: "The current era starts after now, such as when the game clock was moved back, so it cannot be ended yet",
+	},
+	{
+		Err:    common.ErrTxConflict,
+		Status: http.StatusConflict,
+		Detail: "The eras were repeatedly modified concurrently while rolling over, try again",
+	},
+}
+
+var rolloversTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "w1",
+	Name:      "era_rollovers_total",
+	Help:      "Count of era rollover attempts by result.",
+}, []string{"result"})
+
+const (
+	defaultErasPageSize = 50
+	maxErasPageSize     = 100
+)
+
+// rolloverResponse is the body of a successful rollover.
+type rolloverResponse struct {
+	NewEraDTO  EraDTO  `json:"newEraDTO"`
+	PrevEraDTO *EraDTO `json:"prevEraDTO" doc:"The era that was ended, or null if there was no current era."`
+}
+
+// Route registers the eras endpoints. Rollovers are timestamped by clk.
 func Route(
-	v1 *gin.RouterGroup,
-	dbPool *pgxpool.Pool,
+	v1 *openapi.Group,
+	store Store,
+	clk clock.Clock,
 ) {
 	group := v1.Group("/eras")
 
-	group.GET("", func(c *gin.Context) {
+	group.GET("", openapi.Operation{
+		ID:          "getAllEras",
+		Summary:     "Get all eras",
+		Description: "Eras are paged in the order they were created. When there are more, the Link header has the next page's URL.\n",
+		Tags:        []string{"Eras"},
+		Params: []openapi.Param{
+			{
+				Name:        "pageSize",
+				In:          "query",
+				Description: "How many eras to return at most.",
+				Type:        0,
+				Default:     defaultErasPageSize,
+				Minimum:     openapi.Ptr(1.0),
+				Maximum:     openapi.Ptr(float64(maxErasPageSize)),
+			},
+			{
+				Name:        "after",
+				In:          "query",
+				Description: "Only return eras after the era with this ID, as given by the Link header.",
+				Type:        "",
+				Pattern:     "^[0-9]+$",
+			},
+		},
+		Responses: []openapi.Response{
+			{
+				Status:      http.StatusOK,
+				Description: "OK",
+				Body:        []EraDTO{},
+				Headers: []openapi.Header{{
+					Name:        "Link",
+					Description: "The next page's URL with rel=\"next\", if there may be more eras.",
+				}},
+			},
+			{Status: http.StatusInternalServerError, Ref: openapi.ResponseInternalServerError},
+			{Status: http.StatusServiceUnavailable, Ref: openapi.ResponseTimeout},
+		},
+	}, func(c *gin.Context) {
 		slogger := middleware.MustGetSlogger(c)
-		dbQueries := db.New(dbPool)
-		eraQueries := MakeQueries(dbQueries, slogger)
-		allEras, err := eraQueries.GetEras(c)
+		// The query was validated against the spec, so these parse.
+		pageSize := defaultErasPageSize
+		if raw := c.Query("pageSize"); raw != "" {
+			pageSize, _ = strconv.Atoi(raw)
+		}
+		var afterID int64
+		if raw := c.Query("after"); raw != "" {
+			afterID, _ = strconv.ParseInt(raw, 10, 64)
+		}
+
+		eraQueries := MakeQueries(store, slogger)
+		page, err := eraQueries.GetErasPage(c, afterID, int32(pageSize))
 		if err != nil {
 			slogger.ErrorContext(c, "An unexpected error was returned by the DB integration", slog.String("err", err.Error()))
-			c.String(http.StatusInternalServerError, "An unexpected error was returned by the DB integration")
+			problem.Abort(c, http.StatusInternalServerError, "An unexpected error was returned by the DB integration")
 			return
 		}
 
-		eraDTOs := make([]EraDTO, len(allEras))
-		for i, era := range allEras {
+		eraDTOs := make([]EraDTO, len(page))
+		for i, era := range page {
 			eraDTOs[i] = MakeEraDTO(era)
 		}
+		if len(page) == pageSize {
+			next := url.URL{
+				Path: c.Request.URL.Path,
+				RawQuery: url.Values{
+					"pageSize": {strconv.Itoa(pageSize)},
+					"after":    {strconv.FormatInt(page[len(page)-1].ID, 10)},
+				}.Encode(),
+			}
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+		}
 
 		c.JSON(http.StatusOK, eraDTOs)
 	})
 
-	group.GET("/current", func(c *gin.Context) {
+	group.GET("/current", openapi.Operation{
+		ID:      "getCurrEra",
+		Summary: "Get current era",
+		Tags:    []string{"Eras"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "OK", Body: EraDTO{}},
+			{Status: http.StatusInternalServerError, Ref: openapi.ResponseInternalServerError},
+			{Status: http.StatusServiceUnavailable, Ref: openapi.ResponseTimeout},
+		},
+	}, func(c *gin.Context) {
 		slogger := middleware.MustGetSlogger(c)
-		dbQueries := db.New(dbPool)
-		eraQueries := MakeQueries(dbQueries, slogger)
+		eraQueries := MakeQueries(store, slogger)
 		era, err := eraQueries.GetCurrEra(c)
 		if err != nil {
 			if errors.Is(err, ErrNoCurrEra) {
 				slogger.ErrorContext(c, "There is no current era, the game is not initialized yet")
-				c.String(http.StatusInternalServerError, "There is no current era, the game is not initialized yet")
-				return
+			} else {
+				slogger.ErrorContext(c, "An unexpected error was returned by the DB integration", slog.String("err", err.Error()))
 			}
-			slogger.ErrorContext(c, "An unexpected error was returned by the DB integration", slog.String("err", err.Error()))
-			c.String(http.StatusInternalServerError, "An unexpected error was returned by the DB integration")
+			problem.AbortWithErr(c, err, problemMappings)
 			return
 		}
 
 		c.JSON(http.StatusOK, MakeEraDTO(era))
 	})
 
-	group.POST("/rollover", func(c *gin.Context) {
+	group.POST("/rollover", openapi.Operation{
+		ID:      "rollover",
+		Summary: "Rollover eras",
+		Description: "Terminate the current era, soft reset the game, and create a new era.\n\n" +
+			"If no current era exists, create the first era.\n",
+		Tags: []string{"Eras"},
+		Params: []openapi.Param{
+			{
+				Name:     "newEraName",
+				In:       "query",
+				Required: true,
+				Type:     "",
+				Example:  "The new era",
+			},
+			{
+				Name: middleware.IdempotencyKeyHeader,
+				In:   "header",
+				Description: "A unique value, such as a UUID, making the request safe to retry: " +
+					"retries with the same key get the first response instead of rolling over again.",
+				Type: "",
+			},
+		},
+		Responses: []openapi.Response{
+			{
+				Status:      http.StatusCreated,
+				Description: "Created",
+				Body:        rolloverResponse{},
+				Headers: []openapi.Header{{
+					Name:        middleware.IdempotentReplayedHeader,
+					Description: "true if this is the saved response to an earlier request with the same Idempotency-Key.",
+				}},
+			},
+			{Status: http.StatusBadRequest, Description: "Bad Request, such as when the new era's name is blank or a duplicate", Problem: true},
+			{
+				Status: http.StatusConflict,
+				Description: "Conflict, the eras were modified concurrently while rolling over, " +
+					"or a request with the same Idempotency-Key is still being handled, try again; " +
+					"or the current era starts after now, so it cannot be ended yet",
+				Problem: true,
+			},
+			{Status: http.StatusUnprocessableEntity, Description: "Unprocessable Entity, the Idempotency-Key was already used for a different request", Problem: true},
+			{Status: http.StatusInternalServerError, Ref: openapi.ResponseInternalServerError},
+			{Status: http.StatusServiceUnavailable, Ref: openapi.ResponseTimeout},
+		},
+	}, func(c *gin.Context) {
 		slogger := middleware.MustGetSlogger(c)
 		newEraName := c.Query("newEraName")
-		if len(newEraName) == 0 {
-			c.String(http.StatusBadRequest, "Expected query parameter newEraName but not given or was empty")
-			return
-		}
 
-		tx, err := dbPool.BeginTx(c, pgx.TxOptions{IsoLevel: pgx.Serializable})
+		var newEra db.Era
+		var prevEra *db.Era
+		err := store.InSerializableTx(c, slogger, func(tx Store) error {
+			var err error
+			newEra, prevEra, err = Rollover(c, MakeQueries(tx, slogger), tx, slogger, clk.Now().UTC(), newEraName)
+			return err
+		})
 		if err != nil {
-			slogger.ErrorContext(c, "Could not begin serializable transaciton", slog.String("err", err.Error()))
-			c.String(http.StatusInternalServerError, "Could not begin serializable transaciton")
-			return
-		}
-		defer func() {
-			err := tx.Rollback(c)
-			if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-				slogger.ErrorContext(c, "Transaction rollback failed unexpectedly", slog.String("err", err.Error()))
-				c.String(http.StatusInternalServerError, "Transaction rollback failed unexpectedly")
-				return
+			rolloversTotal.WithLabelValues("failed").Inc()
+			if !problem.IsMapped(err, problemMappings) {
+				slogger.ErrorContext(c, "An unexpected error was returned when rolling over the era(s)", slog.String("err", err.Error()))
 			}
-		}()
-
-		dbQueries := db.New(tx)
-		eraQueries := MakeQueries(dbQueries, slogger)
-
-		newEra, prevEra, err := Rollover(c, eraQueries, dbQueries, slogger, time.Now().UTC(), newEraName)
-		if err != nil {
-			if errors.Is(err, ErrWhitespaceEraName) {
-				c.String(http.StatusBadRequest, "Expected query parameter newEraName but not given or was empty")
-				return
-			}
-			if errors.Is(err, ErrDuplicateEraName) {
-				c.String(http.StatusBadRequest, "The given new era's name is a duplicate of another pre-existing era")
-				return
-			}
-			slogger.ErrorContext(c, "An unexpected error was returned when rolling over the era(s)", slog.String("err", err.Error()))
-			c.String(http.StatusInternalServerError, "An unexpected error was returned when rolling over the era(s)")
+			problem.AbortWithErr(c, err, problemMappings)
 			return
 		}
 
-		err = tx.Commit(c)
-		if err != nil {
-			slogger.ErrorContext(c, "An unexpected error was returned when committing the changes", slog.String("err", err.Error()))
-			c.String(http.StatusInternalServerError, "An unexpected error was returned when committing the changes")
-			return
-		}
+		rolloversTotal.WithLabelValues("succeeded").Inc()
 
 		var prevEraDTO *EraDTO
 		if prevEra != nil {
 			p := MakeEraDTO(*prevEra)
 			prevEraDTO = &p
 		}
-		resp := struct {
-			NewEraDTO  EraDTO  `json:"newEraDTO"`
-			PrevEraDTO *EraDTO `json:"prevEraDTO"`
-		}{
+		c.JSON(http.StatusCreated, rolloverResponse{
 			NewEraDTO:  MakeEraDTO(newEra),
 			PrevEraDTO: prevEraDTO,
-		}
-		c.JSON(http.StatusCreated, resp)
+		})
 	})
 }
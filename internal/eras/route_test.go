@@ -0,0 +1,194 @@
+package eras
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/common/middleware"
+	"github.com/sawyerwatts/world-one/internal/common/openapi"
+	"github.com/sawyerwatts/world-one/internal/common/problem"
+)
+
+func newTestRouter(store Store) *gin.Engine {
+	return newTestRouterWithClock(store, clock.System{})
+}
+
+func newTestRouterWithClock(store Store, clk clock.Clock) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(middleware.UseTraceUUIDAndSlogger(context.Background(), discardSlogger()))
+	Route(openapi.NewGroup(openapi.NewSpec(openapi.Info{}), router.Group("/v1")), store, clk)
+	return router
+}
+
+func serve(t *testing.T, router http.Handler, method string, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+func TestRouteGetCurrentWithoutErasIsAProblem(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		router := newTestRouter(store)
+
+		w := serve(t, router, http.MethodGet, "/v1/eras/current")
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected 500, got %d: %s", w.Code, w.Body.String())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != problem.ContentType {
+			t.Fatalf("Expected %s, got %s", problem.ContentType, contentType)
+		}
+		details := decode[problem.Details](t, w)
+		if details.TraceUUID == "" || details.TraceUUID != w.Header().Get(middleware.TraceUUIDHeader) {
+			t.Fatalf("Expected the problem's trace UUID to match the header, got %+v", details)
+		}
+	})
+}
+
+func TestRouteRolloverThenGet(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		router := newTestRouter(store)
+
+		w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=First")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		first := decode[struct {
+			NewEraDTO  EraDTO  `json:"newEraDTO"`
+			PrevEraDTO *EraDTO `json:"prevEraDTO"`
+		}](t, w)
+		if first.NewEraDTO.Name != "First" || first.PrevEraDTO != nil {
+			t.Fatalf("Expected only a new era named First, got %+v", first)
+		}
+
+		w = serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Second")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		second := decode[struct {
+			NewEraDTO  EraDTO  `json:"newEraDTO"`
+			PrevEraDTO *EraDTO `json:"prevEraDTO"`
+		}](t, w)
+		if second.PrevEraDTO == nil || second.PrevEraDTO.ID != first.NewEraDTO.ID {
+			t.Fatalf("Expected era %s to be ended, got %+v", first.NewEraDTO.ID, second.PrevEraDTO)
+		}
+
+		w = serve(t, router, http.MethodGet, "/v1/eras/current")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if curr := decode[EraDTO](t, w); curr.ID != second.NewEraDTO.ID {
+			t.Fatalf("Expected the current era to be %s, got %s", second.NewEraDTO.ID, curr.ID)
+		}
+
+		w = serve(t, router, http.MethodGet, "/v1/eras")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if allEras := decode[[]EraDTO](t, w); len(allEras) != 2 {
+			t.Fatalf("Expected 2 eras, got %d", len(allEras))
+		}
+	})
+}
+
+func TestRouteRolloverRejectsBadNames(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		router := newTestRouter(store)
+
+		for _, target := range []string{
+			"/v1/eras/rollover",
+			"/v1/eras/rollover?newEraName=%20",
+		} {
+			if w := serve(t, router, http.MethodPost, target); w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected 400 for %s, got %d: %s", target, w.Code, w.Body.String())
+			}
+		}
+
+		if w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Taken"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Taken"); w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected 400 for a duplicate name, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestRouteRolloverIsTimestampedByTheClock(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		start := time.Date(2030, time.June, 1, 12, 0, 0, 0, time.UTC)
+		clk := clock.NewFake(start)
+		router := newTestRouterWithClock(store, clk)
+
+		if w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=First"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		clk.Advance(90 * 24 * time.Hour)
+		w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Second")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		resp := decode[struct {
+			NewEraDTO  EraDTO  `json:"newEraDTO"`
+			PrevEraDTO *EraDTO `json:"prevEraDTO"`
+		}](t, w)
+		if !resp.PrevEraDTO.StartTime.Equal(start) || !resp.PrevEraDTO.EndTime.Equal(clk.Now()) {
+			t.Fatalf("Expected the first era to span %s to %s, got %+v", start, clk.Now(), resp.PrevEraDTO)
+		}
+		if !resp.NewEraDTO.StartTime.Equal(clk.Now()) {
+			t.Fatalf("Expected the second era to start at %s, got %s", clk.Now(), resp.NewEraDTO.StartTime)
+		}
+	})
+}
+
+func TestRouteRolloverRejectsAClockBeforeTheCurrentEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		start := time.Date(2030, time.June, 1, 12, 0, 0, 0, time.UTC)
+		clk := clock.NewFake(start)
+		router := newTestRouterWithClock(store, clk)
+
+		if w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=First"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		clk.Advance(-time.Hour)
+		w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Second")
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected 409, got %d: %s", w.Code, w.Body.String())
+		}
+		if details := decode[problem.Details](t, w); details.TraceUUID == "" {
+			t.Fatalf("Expected a problem, got %s", w.Body.String())
+		}
+
+		curr := decode[EraDTO](t, serve(t, router, http.MethodGet, "/v1/eras/current"))
+		if curr.Name != "First" || !curr.StartTime.Equal(start) {
+			t.Fatalf("Expected the first era to still be current, got %+v", curr)
+		}
+
+		// At the start of the current era, it can end, if only just.
+		clk.Set(start)
+		if w := serve(t, router, http.MethodPost, "/v1/eras/rollover?newEraName=Second"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
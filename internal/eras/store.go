@@ -0,0 +1,55 @@
+package eras
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/db"
+)
+
+// Store persists eras. NewPgStore is backed by Postgres, and NewMemStore is
+// an in-memory fake for tests that upholds the same invariants.
+//
+// Errors match those of the sqlc queries, such as pgx.ErrNoRows and
+// *pgconn.PgError, so they can be classified by dberr.
+type Store interface {
+	GetCurrEra(ctx context.Context) (db.Era, error)
+	GetEras(ctx context.Context) ([]db.Era, error)
+	// GetErasPage returns up to PageSize eras with IDs after AfterID, ordered
+	// by ID.
+	GetErasPage(ctx context.Context, arg db.GetErasPageParams) ([]db.Era, error)
+	InsertEra(ctx context.Context, arg db.InsertEraParams) (db.Era, error)
+	UpdateEra(ctx context.Context, arg db.UpdateEraParams) (db.Era, error)
+	// TruncateEra deletes every era, returning how many were deleted.
+	TruncateEra(ctx context.Context) (int64, error)
+	// InSerializableTx runs fn with a Store whose operations are in a single
+	// serializable transaction, committed if fn returns nil. fn may be run
+	// more than once, so it must not have side effects outside of tx. If the
+	// Store is already a transaction, fn is run in it.
+	InSerializableTx(ctx context.Context, slogger *slog.Logger, fn func(tx Store) error) error
+}
+
+type pgStore struct {
+	*db.Queries
+	// dbPool is nil if the store is a transaction.
+	dbPool *pgxpool.Pool
+}
+
+func NewPgStore(dbPool *pgxpool.Pool) Store {
+	return pgStore{
+		Queries: db.New(dbPool),
+		dbPool:  dbPool,
+	}
+}
+
+func (s pgStore) InSerializableTx(ctx context.Context, slogger *slog.Logger, fn func(tx Store) error) error {
+	if s.dbPool == nil {
+		return fn(s)
+	}
+	return common.RunInTx(ctx, s.dbPool, slogger, common.TxOptions{IsoLevel: pgx.Serializable}, func(tx pgx.Tx) error {
+		return fn(pgStore{Queries: s.Queries.WithTx(tx)})
+	})
+}
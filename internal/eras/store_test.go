@@ -0,0 +1,311 @@
+package eras
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/dberr"
+	"github.com/sawyerwatts/world-one/internal/db"
+)
+
+// These tests are the conformance suite for Store, ensuring MemStore behaves
+// like the Postgres-backed store.
+
+func TestStoreGetCurrEraWithoutErasIsNoRows(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		_, err := store.GetCurrEra(context.Background())
+		if !errors.Is(dberr.Classify(err), dberr.ErrNoRows) {
+			t.Fatalf("Expected no rows, got %v", err)
+		}
+	})
+}
+
+func TestStoreInsertEraSetsGeneratedColumns(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		first := mustInsertEra(t, store, "First", start, start.Add(time.Hour))
+		second := mustInsertEra(t, store, "Second", start.Add(time.Hour), common.UninitializedEndDate)
+		if first.ID == second.ID {
+			t.Fatalf("Expected distinct IDs, got %d twice", first.ID)
+		}
+		if first.CreateTime.IsZero() || !first.CreateTime.Equal(first.UpdateTime) {
+			t.Fatalf("Expected create and update times to be set to the same time, got %s and %s", first.CreateTime, first.UpdateTime)
+		}
+		if !first.StartTime.Equal(start) {
+			t.Fatalf("Expected start time %s, got %s", start, first.StartTime)
+		}
+
+		allEras, err := store.GetEras(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(allEras) != 2 {
+			t.Fatalf("Expected 2 eras, got %d", len(allEras))
+		}
+	})
+}
+
+func TestStoreGetErasPageIsOrderedByID(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		var inserted []db.Era
+		for _, name := range []string{"First", "Second", "Third"} {
+			inserted = append(inserted, mustInsertEra(t, store, name, start, start.Add(time.Hour)))
+		}
+
+		page, err := store.GetErasPage(ctx, db.GetErasPageParams{AfterID: 0, PageSize: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].ID != inserted[0].ID || page[1].ID != inserted[1].ID {
+			t.Fatalf("Expected the first two eras, got %+v", page)
+		}
+
+		page, err = store.GetErasPage(ctx, db.GetErasPageParams{AfterID: page[1].ID, PageSize: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].ID != inserted[2].ID {
+			t.Fatalf("Expected the third era, got %+v", page)
+		}
+
+		page, err = store.GetErasPage(ctx, db.GetErasPageParams{AfterID: inserted[2].ID, PageSize: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 0 {
+			t.Fatalf("Expected no eras, got %+v", page)
+		}
+	})
+}
+
+func TestStoreTruncateEraDeletesEveryEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		mustInsertEra(t, store, "First", start, start.Add(time.Hour))
+		mustInsertEra(t, store, "Second", start.Add(time.Hour), common.UninitializedEndDate)
+
+		deleted, err := store.TruncateEra(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 2 {
+			t.Fatalf("Expected 2 eras to be deleted, got %d", deleted)
+		}
+		all, err := store.GetEras(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 0 {
+			t.Fatalf("Expected no eras, got %+v", all)
+		}
+
+		// The names are free again.
+		mustInsertEra(t, store, "First", start, common.UninitializedEndDate)
+	})
+}
+
+func TestStoreEnforcesUniqueNames(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		mustInsertEra(t, store, "Taken", start, start.Add(time.Hour))
+		other := mustInsertEra(t, store, "Other", start, start.Add(time.Hour))
+
+		_, err := store.InsertEra(ctx, db.InsertEraParams{Name: "Taken", StartTime: start, EndTime: start})
+		assertUniqueViolation(t, err, eraNameUniqueConstraint)
+
+		_, err = store.UpdateEra(ctx, db.UpdateEraParams{
+			ID:         other.ID,
+			Name:       "Taken",
+			StartTime:  other.StartTime,
+			EndTime:    other.EndTime,
+			UpdateTime: other.UpdateTime,
+		})
+		assertUniqueViolation(t, err, eraNameUniqueConstraint)
+	})
+}
+
+func TestStoreEnforcesASingleOpenEra(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		mustInsertEra(t, store, "Open", start, common.UninitializedEndDate)
+		ended := mustInsertEra(t, store, "Ended", start, start.Add(time.Hour))
+
+		_, err := store.InsertEra(ctx, db.InsertEraParams{Name: "Also open", StartTime: start, EndTime: common.UninitializedEndDate})
+		assertUniqueViolation(t, err, singleOpenEraConstraint)
+
+		_, err = store.UpdateEra(ctx, db.UpdateEraParams{
+			ID:         ended.ID,
+			Name:       ended.Name,
+			StartTime:  ended.StartTime,
+			EndTime:    common.UninitializedEndDate,
+			UpdateTime: ended.UpdateTime,
+		})
+		assertUniqueViolation(t, err, singleOpenEraConstraint)
+	})
+}
+
+func TestStoreUpdateEraChecksUpdateTime(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		era := mustInsertEra(t, store, "First", start, common.UninitializedEndDate)
+
+		params := db.UpdateEraParams{
+			ID:         era.ID,
+			Name:       era.Name,
+			StartTime:  era.StartTime,
+			EndTime:    start.Add(time.Hour),
+			UpdateTime: era.UpdateTime.Add(-time.Second),
+		}
+		if _, err := store.UpdateEra(ctx, params); !errors.Is(dberr.Classify(err), dberr.ErrNoRows) {
+			t.Fatalf("Expected no rows for a stale update time, got %v", err)
+		}
+
+		params.UpdateTime = era.UpdateTime
+		updated, err := store.UpdateEra(ctx, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated.EndTime.Equal(params.EndTime) || updated.UpdateTime.Before(era.UpdateTime) {
+			t.Fatalf("Expected the end and update times to be updated, got %+v", updated)
+		}
+	})
+}
+
+func TestStoreTransactionsCommitOrRollBack(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		errAbort := errors.New("abort")
+
+		err := store.InSerializableTx(ctx, slogger, func(tx Store) error {
+			mustInsertEra(t, tx, "Rolled back", start, start)
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Expected fn's error, got %v", err)
+		}
+		err = store.InSerializableTx(ctx, slogger, func(tx Store) error {
+			mustInsertEra(t, tx, "Committed", start, start)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		allEras, err := store.GetEras(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(allEras) != 1 || allEras[0].Name != "Committed" {
+			t.Fatalf("Expected only the committed era, got %+v", allEras)
+		}
+	})
+}
+
+func TestStoreTransactionsRetryConflicts(t *testing.T) {
+	t.Parallel()
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		slogger := discardSlogger()
+		start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		mustInsertEra(t, store, "First", start, common.UninitializedEndDate)
+
+		// renameInTx renames the current era in a transaction, first renaming
+		// it outside of the transaction on the attempts conflicts says to.
+		renameInTx := func(name string, conflicts func(attempt int) bool) (int, error) {
+			attempts := 0
+			err := store.InSerializableTx(ctx, slogger, func(tx Store) error {
+				attempts++
+				era, err := tx.GetCurrEra(ctx)
+				if err != nil {
+					return err
+				}
+				if conflicts(attempts) {
+					current, err := store.GetCurrEra(ctx)
+					if err != nil {
+						return err
+					}
+					_, err = store.UpdateEra(ctx, db.UpdateEraParams{
+						ID:         current.ID,
+						Name:       fmt.Sprintf("Concurrent %d", attempts),
+						StartTime:  current.StartTime,
+						EndTime:    current.EndTime,
+						UpdateTime: current.UpdateTime,
+					})
+					if err != nil {
+						return err
+					}
+				}
+				_, err = tx.UpdateEra(ctx, db.UpdateEraParams{
+					ID:         era.ID,
+					Name:       name,
+					StartTime:  era.StartTime,
+					EndTime:    era.EndTime,
+					UpdateTime: era.UpdateTime,
+				})
+				return err
+			})
+			return attempts, err
+		}
+
+		attempts, err := renameInTx("Retried", func(attempt int) bool { return attempt == 1 })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 2 {
+			t.Fatalf("Expected fn to be run again after the conflict, got %d attempts", attempts)
+		}
+		era, err := store.GetCurrEra(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if era.Name != "Retried" {
+			t.Fatalf("Expected the retry to be committed, got %+v", era)
+		}
+
+		attempts, err = renameInTx("Never committed", func(int) bool { return true })
+		if !errors.Is(err, common.ErrTxConflict) || !dberr.IsRetryable(err) {
+			t.Fatalf("Expected a conflict wrapping the last serialization failure, got %v", err)
+		}
+		if attempts < 2 {
+			t.Fatalf("Expected fn to be retried before giving up, got %d attempts", attempts)
+		}
+	})
+}
+
+func mustInsertEra(t *testing.T, store Store, name string, start time.Time, end time.Time) db.Era {
+	t.Helper()
+	era, err := store.InsertEra(context.Background(), db.InsertEraParams{Name: name, StartTime: start, EndTime: end})
+	if err != nil {
+		t.Fatalf("Failed to insert era %s: %v", name, err)
+	}
+	return era
+}
+
+func assertUniqueViolation(t *testing.T, err error, constraint string) {
+	t.Helper()
+	err = dberr.Classify(err)
+	if !errors.Is(err, dberr.ErrUniqueViolation) || dberr.Constraint(err) != constraint {
+		t.Fatalf("Expected a violation of %s, got %v", constraint, err)
+	}
+}
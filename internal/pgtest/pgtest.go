@@ -0,0 +1,240 @@
+// Package pgtest gives tests their own throwaway Postgres database with every
+// migration applied.
+//
+// The server is found in this order:
+//
+// 1. W1_TEST_PGURL, the URL of a server and a user that can create databases.
+//
+// 2. The initdb, pg_ctl, and postgres binaries in W1_TEST_PGBIN or on PATH, in
+// which case a server is started in a temp dir and stopped by Main.
+//
+// If neither are available, tests using NewPool are skipped. Set
+// W1_TEST_REQUIRE_DB=true (as CI should) to fail them instead.
+//
+// A template database is migrated once per test binary, and each test's
+// database is cloned from it, which is much faster than migrating each one.
+// Packages using NewPool must call Main from their TestMain so that the
+// template and any started server are cleaned up.
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sawyerwatts/world-one/sql/migrations"
+)
+
+const (
+	pgURLEnv     = "W1_TEST_PGURL"
+	pgBinEnv     = "W1_TEST_PGBIN"
+	requireDBEnv = "W1_TEST_REQUIRE_DB"
+)
+
+var (
+	setupOnce sync.Once
+	setupErr  error
+	// serverURL connects to the server's maintenance database.
+	serverURL    *url.URL
+	templateName string
+	stopServer   func()
+)
+
+// Main runs the tests and then drops the template database and stops any
+// server that was started. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		pgtest.Main(m)
+//	}
+func Main(m *testing.M) {
+	code := m.Run()
+	teardown()
+	os.Exit(code)
+}
+
+// NewPool creates a database for t from the migrated template and returns a
+// pool connected to it. The pool is closed and the database dropped when t
+// finishes.
+func NewPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	setupOnce.Do(func() { setupErr = setup() })
+	if setupErr != nil {
+		if os.Getenv(requireDBEnv) == "true" {
+			t.Fatalf("Postgres is required but unavailable: %v", setupErr)
+		}
+		t.Skipf("Skipping, Postgres is unavailable: %v", setupErr)
+	}
+
+	ctx := context.Background()
+	name := "w1_test_" + randomSuffix()
+	if err := execOnServer(ctx, fmt.Sprintf("create database %s template %s", name, templateName)); err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	// The app assumes the database's timezone is UTC, which a server given by
+	// W1_TEST_PGURL may not be configured to.
+	poolURL, _ := url.Parse(databaseURL(name))
+	query := poolURL.Query()
+	query.Set("timezone", "UTC")
+	poolURL.RawQuery = query.Encode()
+	pool, err := pgxpool.New(ctx, poolURL.String())
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		if err := execOnServer(context.Background(), fmt.Sprintf("drop database if exists %s with (force)", name)); err != nil {
+			t.Errorf("Failed to drop test database %s: %v", name, err)
+		}
+	})
+	return pool
+}
+
+func setup() error {
+	if rawURL := os.Getenv(pgURLEnv); rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("%s is not a URL", pgURLEnv)
+		}
+		serverURL = u
+	} else {
+		u, stop, err := startServer()
+		if err != nil {
+			return err
+		}
+		serverURL = u
+		stopServer = stop
+	}
+
+	templateName = "w1_template_" + randomSuffix()
+	ctx := context.Background()
+	if err := execOnServer(ctx, "create database "+templateName); err != nil {
+		return fmt.Errorf("failed to create template database: %w", err)
+	}
+
+	slogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m, err := migrations.New(databaseURL(templateName), slogger, 15*time.Second)
+	if err != nil {
+		return err
+	}
+	err = m.Up()
+	// Closing disconnects from the template, which must have no connections
+	// to be cloned.
+	sourceErr, dbErr := m.Close()
+	if err != nil {
+		return fmt.Errorf("failed to migrate template database: %w", err)
+	}
+	return errors.Join(sourceErr, dbErr)
+}
+
+func teardown() {
+	if templateName != "" {
+		_ = execOnServer(context.Background(), fmt.Sprintf("drop database if exists %s with (force)", templateName))
+	}
+	if stopServer != nil {
+		stopServer()
+	}
+}
+
+// startServer initializes and starts a Postgres server in a temp dir,
+// listening only on localhost with trust auth.
+func startServer() (*url.URL, func(), error) {
+	binDir := os.Getenv(pgBinEnv)
+	lookPath := func(name string) (string, error) {
+		if binDir != "" {
+			return filepath.Join(binDir, name), nil
+		}
+		return exec.LookPath(name)
+	}
+	initdb, err := lookPath("initdb")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not set and initdb was not found: %w", pgURLEnv, err)
+	}
+	pgCtl, err := lookPath("pg_ctl")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not set and pg_ctl was not found: %w", pgURLEnv, err)
+	}
+
+	dir, err := os.MkdirTemp("", "w1-pgtest-")
+	if err != nil {
+		return nil, nil, err
+	}
+	dataDir := filepath.Join(dir, "data")
+	out, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("initdb failed: %w: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off -c timezone=UTC", port, dir)
+	out, err = exec.Command(pgCtl, "start", "-w", "-D", dataDir, "-l", filepath.Join(dir, "postgres.log"), "-o", options).CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("pg_ctl start failed: %w: %s", err, out)
+	}
+
+	stop := func() {
+		_ = exec.Command(pgCtl, "stop", "-D", dataDir, "-m", "immediate").Run()
+		_ = os.RemoveAll(dir)
+	}
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.User("postgres"),
+		Host:     net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Path:     "/postgres",
+		RawQuery: "sslmode=disable",
+	}
+	return u, stop, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func execOnServer(ctx context.Context, sql string) error {
+	conn, err := pgx.Connect(ctx, serverURL.String())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+func databaseURL(name string) string {
+	u := *serverURL
+	u.Path = "/" + name
+	return u.String()
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
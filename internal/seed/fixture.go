@@ -0,0 +1,184 @@
+// Package seed loads declarative fixtures into the database, so that a fresh
+// database can be brought to a playable state, such as having a current era.
+package seed
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed scenarios/*.yml
+var scenarios embed.FS
+
+var (
+	ErrUnknownScenario = errors.New("unknown scenario")
+	ErrInvalidFixture  = errors.New("invalid fixture")
+)
+
+// Fixture declares what to seed. It's written in YAML or JSON.
+//
+// Players, Factions, and Tasks are reserved for resources that don't exist
+// yet; fixtures declaring them are rejected rather than partially applied.
+type Fixture struct {
+	Description string       `yaml:"description"`
+	Eras        []EraFixture `yaml:"eras"`
+	Players     []any        `yaml:"players"`
+	Factions    []any        `yaml:"factions"`
+	Tasks       []any        `yaml:"tasks"`
+}
+
+// EraFixture declares an era, identified by its name. Start and End are
+// RFC 3339 times or durations relative to when the fixture is seeded, such
+// as -72h. An era without an End is the current era. Like eras made by
+// rollovers, eras can't overlap each other or the eras already seeded.
+type EraFixture struct {
+	Name  string `yaml:"name"`
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// Parse decodes a YAML or JSON fixture, rejecting unknown fields so that
+// typos aren't silently ignored.
+func Parse(r io.Reader) (Fixture, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	var fixture Fixture
+	if err := decoder.Decode(&fixture); err != nil && !errors.Is(err, io.EOF) {
+		return Fixture{}, fmt.Errorf("%w: %w", ErrInvalidFixture, err)
+	}
+	return fixture, nil
+}
+
+// Scenarios lists the names of the built in fixtures.
+func Scenarios() []string {
+	entries, err := fs.ReadDir(scenarios, "scenarios")
+	if err != nil {
+		panic(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Scenario returns the built in fixture named name.
+func Scenario(name string) (Fixture, error) {
+	b, err := scenarios.ReadFile("scenarios/" + name + ".yml")
+	if err != nil {
+		return Fixture{}, fmt.Errorf("%w %q, expected one of %s", ErrUnknownScenario, name, strings.Join(Scenarios(), ", "))
+	}
+	fixture, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		return Fixture{}, fmt.Errorf("scenario %s: %w", name, err)
+	}
+	return fixture, nil
+}
+
+// Validate reports the problems Seed would reject the fixture for, without
+// needing a database.
+func (f Fixture) Validate() error {
+	_, err := f.resolveEras(time.Now())
+	return err
+}
+
+// resolvedEra is an EraFixture with its times resolved.
+type resolvedEra struct {
+	name      string
+	startTime time.Time
+	endTime   time.Time
+}
+
+// resolveEras validates the eras and resolves their times relative to now,
+// ordering them by their start times.
+func (f Fixture) resolveEras(now time.Time) ([]resolvedEra, error) {
+	var unsupported []string
+	for section, values := range map[string][]any{"players": f.Players, "factions": f.Factions, "tasks": f.Tasks} {
+		if len(values) > 0 {
+			unsupported = append(unsupported, section)
+		}
+	}
+	if len(unsupported) > 0 {
+		slices.Sort(unsupported)
+		return nil, fmt.Errorf("%w: %s cannot be seeded since they aren't implemented yet", ErrInvalidFixture, strings.Join(unsupported, ", "))
+	}
+
+	resolved := make([]resolvedEra, 0, len(f.Eras))
+	names := make(map[string]bool, len(f.Eras))
+	hasCurrent := false
+	for i, era := range f.Eras {
+		name := strings.TrimSpace(era.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: eras[%d] has no name", ErrInvalidFixture, i)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("%w: era %q is declared twice", ErrInvalidFixture, name)
+		}
+		names[name] = true
+
+		start, err := resolveTime(era.Start, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: era %q start: %w", ErrInvalidFixture, name, err)
+		}
+		end := common.UninitializedEndDate
+		if era.End == "" {
+			if hasCurrent {
+				return nil, fmt.Errorf("%w: era %q has no end, but only one era can be current", ErrInvalidFixture, name)
+			}
+			hasCurrent = true
+		} else {
+			end, err = resolveTime(era.End, now)
+			if err != nil {
+				return nil, fmt.Errorf("%w: era %q end: %w", ErrInvalidFixture, name, err)
+			}
+			if !end.After(start) {
+				return nil, fmt.Errorf("%w: era %q ends before it starts", ErrInvalidFixture, name)
+			}
+		}
+		resolved = append(resolved, resolvedEra{name: name, startTime: start, endTime: end})
+	}
+
+	// The eras must form a timeline that rollovers could have made, so none
+	// can start before the previous one ended, and none can follow the
+	// current era.
+	slices.SortStableFunc(resolved, func(a, b resolvedEra) int { return a.startTime.Compare(b.startTime) })
+	for i := 1; i < len(resolved); i++ {
+		prev, era := resolved[i-1], resolved[i]
+		if era.startTime.Before(prev.endTime) {
+			return nil, fmt.Errorf("%w: era %q starts before era %q ends", ErrInvalidFixture, era.name, prev.name)
+		}
+	}
+	return resolved, nil
+}
+
+// overlaps reports whether era and other were ever both running.
+func (era resolvedEra) overlaps(other db.Era) bool {
+	return era.startTime.Before(other.EndTime) && other.StartTime.Before(era.endTime)
+}
+
+func resolveTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or a duration, got %q", value)
+	}
+	return now.Add(d).UTC(), nil
+}
@@ -0,0 +1,112 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/db"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+// ErrCurrentEraConflict is returned when the fixture declares a current era
+// but the database already has a different one.
+var ErrCurrentEraConflict = errors.New("the database already has a different current era")
+
+type Options struct {
+	// Reset deletes the existing data before seeding.
+	Reset bool
+}
+
+// Report summarizes what Seed did.
+type Report struct {
+	ErasDeleted  int64
+	ErasInserted int
+	// ErasSkipped were already present, by name, so they were left as is.
+	ErasSkipped int
+}
+
+// Seed loads fixture in a single transaction, so either all of it is seeded
+// or none of it is. Relative times in the fixture are resolved against now.
+//
+// Seeding is idempotent: what's already present (matched by name) is skipped
+// rather than duplicated or changed, so seeding the same fixture twice is a
+// no-op. Resources are seeded in dependency order, which is only eras until
+// more resources exist.
+func Seed(
+	ctx context.Context,
+	store eras.Store,
+	slogger *slog.Logger,
+	now time.Time,
+	fixture Fixture,
+	opts Options,
+) (Report, error) {
+	fixtureEras, err := fixture.resolveEras(now)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+	err = store.InSerializableTx(ctx, slogger, func(tx eras.Store) error {
+		report = Report{}
+		if opts.Reset {
+			deleted, err := tx.TruncateEra(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to delete the existing eras: %w", err)
+			}
+			report.ErasDeleted = deleted
+			slogger.WarnContext(ctx, "Deleted the existing eras before seeding", slog.Int64("count", deleted))
+		}
+		return seedEras(ctx, tx, slogger, fixtureEras, &report)
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+func seedEras(ctx context.Context, tx eras.Store, slogger *slog.Logger, fixtureEras []resolvedEra, report *Report) error {
+	existing, err := tx.GetEras(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the existing eras: %w", err)
+	}
+	existingNames := make(map[string]bool, len(existing))
+	var currEra *db.Era
+	for _, era := range existing {
+		existingNames[era.Name] = true
+		if era.EndTime.Equal(common.UninitializedEndDate) {
+			currEra = &era
+		}
+	}
+
+	for _, era := range fixtureEras {
+		if existingNames[era.name] {
+			slogger.InfoContext(ctx, "Skipping an era that already exists", slog.String("name", era.name))
+			report.ErasSkipped++
+			continue
+		}
+		if era.endTime.Equal(common.UninitializedEndDate) && currEra != nil {
+			return fmt.Errorf("%w: the fixture's current era is %q, but %q is current; reset the database or roll over instead",
+				ErrCurrentEraConflict, era.name, currEra.Name)
+		}
+		for _, other := range existing {
+			if era.overlaps(other) {
+				return fmt.Errorf("%w: era %q overlaps the existing era %q", ErrInvalidFixture, era.name, other.Name)
+			}
+		}
+
+		if _, err := tx.InsertEra(ctx, db.InsertEraParams{
+			Name:      era.name,
+			StartTime: era.startTime,
+			EndTime:   era.endTime,
+		}); err != nil {
+			return fmt.Errorf("failed to insert era %q: %w", era.name, err)
+		}
+		slogger.InfoContext(ctx, "Inserted an era", slog.String("name", era.name))
+		report.ErasInserted++
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sawyerwatts/world-one/internal/common"
+	"github.com/sawyerwatts/world-one/internal/common/clock"
+	"github.com/sawyerwatts/world-one/internal/eras"
+)
+
+var now = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func discardSlogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestScenariosSeed(t *testing.T) {
+	names := Scenarios()
+	if len(names) == 0 {
+		t.Fatal("Expected built in scenarios")
+	}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			fixture, err := Scenario(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := eras.NewMemStore(clock.System{})
+			report, err := Seed(context.Background(), store, discardSlogger(), now, fixture, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.ErasInserted != len(fixture.Eras) {
+				t.Fatalf("Expected %d eras to be inserted, got %+v", len(fixture.Eras), report)
+			}
+			// Every scenario should leave the game playable.
+			if _, err := store.GetCurrEra(context.Background()); err != nil {
+				t.Fatalf("Expected a current era: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnknownScenario(t *testing.T) {
+	if _, err := Scenario("nope"); !errors.Is(err, ErrUnknownScenario) {
+		t.Fatalf("Expected ErrUnknownScenario, got %v", err)
+	}
+}
+
+func TestSeedIsIdempotent(t *testing.T) {
+	fixture, err := Scenario("mid-era")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := eras.NewMemStore(clock.System{})
+	if _, err := Seed(context.Background(), store, discardSlogger(), now, fixture, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Seed(context.Background(), store, discardSlogger(), now.Add(time.Hour), fixture, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ErasInserted != 0 || report.ErasSkipped != len(fixture.Eras) {
+		t.Fatalf("Expected every era to be skipped, got %+v", report)
+	}
+	all, _ := store.GetEras(context.Background())
+	if len(all) != len(fixture.Eras) {
+		t.Fatalf("Expected %d eras, got %d", len(fixture.Eras), len(all))
+	}
+}
+
+func TestSeedInsertsErasInStartOrder(t *testing.T) {
+	fixture, err := Parse(strings.NewReader(`{
+		"eras": [
+			{"name": "Current", "start": "-1h"},
+			{"name": "Past", "start": "2024-01-01T00:00:00Z", "end": "-1h"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := eras.NewMemStore(clock.System{})
+	if _, err := Seed(context.Background(), store, discardSlogger(), now, fixture, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := store.GetEras(context.Background())
+	if len(all) != 2 || all[0].Name != "Past" || all[1].Name != "Current" {
+		t.Fatalf("Expected Past then Current, got %+v", all)
+	}
+	if !all[1].StartTime.Equal(now.Add(-time.Hour)) || !all[1].EndTime.Equal(common.UninitializedEndDate) {
+		t.Fatalf("Expected Current's times to be resolved relative to now, got %+v", all[1])
+	}
+}
+
+func TestSeedConflictingCurrentEraNeedsReset(t *testing.T) {
+	store := eras.NewMemStore(clock.System{})
+	fresh, err := Scenario("fresh-game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Seed(context.Background(), store, discardSlogger(), now, fresh, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	midEra, err := Scenario("mid-era")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The First Era is skipped by name, but The Third Era can't also be
+	// current.
+	if _, err := Seed(context.Background(), store, discardSlogger(), now, midEra, Options{}); !errors.Is(err, ErrCurrentEraConflict) {
+		t.Fatalf("Expected ErrCurrentEraConflict, got %v", err)
+	}
+	all, _ := store.GetEras(context.Background())
+	if len(all) != 1 {
+		t.Fatalf("Expected the failed seed to be rolled back, got %+v", all)
+	}
+
+	report, err := Seed(context.Background(), store, discardSlogger(), now, midEra, Options{Reset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ErasDeleted != 1 || report.ErasInserted != len(midEra.Eras) {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+}
+
+func TestInvalidFixtures(t *testing.T) {
+	for name, tc := range map[string]struct {
+		// existing is seeded before doc, if set.
+		existing string
+		doc      string
+	}{
+		"unknown field":                 {doc: "eras:\n  - name: First\n    begin: 0s\n"},
+		"unsupported resource":          {doc: "players:\n  - name: Sawyer\n"},
+		"blank name":                    {doc: "eras:\n  - name: ' '\n    start: 0s\n"},
+		"duplicate name":                {doc: "eras:\n  - {name: First, start: -2h, end: -1h}\n  - {name: First, start: -1h}\n"},
+		"two current eras":              {doc: "eras:\n  - {name: First, start: -2h}\n  - {name: Second, start: -1h}\n"},
+		"ends before starting":          {doc: "eras:\n  - {name: First, start: -1h, end: -2h}\n"},
+		"invalid time":                  {doc: "eras:\n  - {name: First, start: yesterday}\n"},
+		"overlapping eras":              {doc: "eras:\n  - {name: First, start: -3h, end: -1h}\n  - {name: Second, start: -2h, end: 0s}\n"},
+		"ends after the current starts": {doc: "eras:\n  - {name: Current, start: -2h}\n  - {name: Past, start: -3h, end: -1h}\n"},
+		"starts after the current":      {doc: "eras:\n  - {name: Current, start: -2h}\n  - {name: Later, start: -1h, end: 0s}\n"},
+		"overlaps an existing era": {
+			existing: "eras:\n  - {name: Existing, start: -3h, end: -1h}\n",
+			doc:      "eras:\n  - {name: First, start: -2h, end: 0s}\n",
+		},
+		"overlaps the existing current era": {
+			existing: "eras:\n  - {name: Existing, start: -3h}\n",
+			doc:      "eras:\n  - {name: First, start: -2h, end: -1h}\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := eras.NewMemStore(clock.System{})
+			if tc.existing != "" {
+				existing, err := Parse(strings.NewReader(tc.existing))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := Seed(context.Background(), store, discardSlogger(), now, existing, Options{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fixture, err := Parse(strings.NewReader(tc.doc))
+			if err == nil {
+				_, err = Seed(context.Background(), store, discardSlogger(), now, fixture, Options{})
+			}
+			if !errors.Is(err, ErrInvalidFixture) {
+				t.Fatalf("Expected ErrInvalidFixture, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+begin;
+
+drop index if exists eras_single_open_era;
+
+commit;
@@ -0,0 +1,8 @@
+begin;
+
+-- Only the current era may have the uninitialized end date.
+create unique index if not exists eras_single_open_era
+		on eras ((true))
+		where end_time = '2200-01-01 00:00:00+00';
+
+commit;
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the pgx database/sql driver.
+)
+
+// New returns a migrate.Migrate that applies the embedded migrations to the
+// database at pgURL. The caller must Close it.
+//
+// Each operation holds a Postgres advisory lock (taken by golang-migrate's pgx
+// driver via pg_advisory_lock) so that multiple instances migrating at once
+// are serialized; lockTimeout is how long to wait for it.
+func New(pgURL string, slogger *slog.Logger, lockTimeout time.Duration) (*migrate.Migrate, error) {
+	source, err := iofs.New(FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	db, err := sql.Open("pgx", pgURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database for migrations: %w", err)
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		_ = driver.Close()
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+	m.Log = migrateLogger{slogger: slogger}
+	m.LockTimeout = lockTimeout
+	return m, nil
+}
+
+// migrateLogger adapts a slogger to migrate.Logger.
+type migrateLogger struct {
+	slogger *slog.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.slogger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
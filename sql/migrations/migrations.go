@@ -0,0 +1,39 @@
+// Package migrations embeds the SQL migrations so that the binary knows
+// which schema version it was built for (and can apply them).
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// LatestVersion returns the highest version of the embedded migrations, which
+// is the schema version the binary expects.
+func LatestVersion() (uint, error) {
+	entries, err := fs.ReadDir(FS, ".")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	var latest uint
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".up.sql") {
+			continue
+		}
+		versionStr, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			return 0, fmt.Errorf("embedded migration %s does not start with a version", entry.Name())
+		}
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("embedded migration %s does not start with a version: %w", entry.Name(), err)
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
@@ -2,6 +2,13 @@
 select *
 from eras;
 
+-- name: GetErasPage :many
+select *
+from eras
+where id > @after_id
+order by id
+limit @page_size;
+
 -- name: GetCurrEra :one
 select *
 from eras